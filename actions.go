@@ -3,7 +3,9 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"sync"
+	"time"
 )
 
 func (e *Episode) AddToQueue(action string) ([]*Episode, error) {
@@ -59,28 +61,62 @@ func RemoveEpisodesFromQueue(episodes []*Episode) ([]*Episode, error) {
 	return processUpNextResponse(&response)
 }
 
+// up next change actions used by `/up_next/sync`
+const upNextActionReplace = 5
+
+func ReplaceQueue(episodes []*Episode) ([]*Episode, error) {
+	if upNextServerModified == 0 {
+		if _, err := GetUpNext(true); err != nil {
+			return nil, err
+		}
+	}
+	episodeList := make([]map[string]any, len(episodes))
+	for i, e := range episodes {
+		if e.UUID == "" || e.PodcastUUID == "" {
+			return nil, fmt.Errorf("episode info missing")
+		}
+		episodeList[i] = map[string]any{
+			"uuid":      e.UUID,
+			"podcast":   e.PodcastUUID,
+			"title":     e.Title,
+			"url":       e.URL,
+			"published": e.Date.UTC().Format(time.RFC3339),
+		}
+	}
+	now := time.Now().UnixMilli()
+	body := map[string]any{
+		"deviceTime": now,
+		"version":    "2",
+		"upNext": map[string]any{
+			"serverModified": upNextServerModified,
+			"changes": []map[string]any{
+				{
+					"action":   upNextActionReplace,
+					"modified": now,
+					"episodes": episodeList,
+				},
+			},
+		},
+	}
+	if err := PocketCastsRequest("/up_next/sync", &body, nil); err != nil {
+		return nil, err
+	}
+	return GetUpNext(true)
+}
+
 func ArchiveEpisodes(episodes []*Episode, markAsPlayed bool) error {
 	if len(episodes) == 0 {
 		return fmt.Errorf("no episodes to archive")
 	}
-	episodeList := make([]map[string]string, len(episodes))
-	for i, e := range episodes {
+	for _, e := range episodes {
 		if e.UUID == "" || e.PodcastUUID == "" {
 			return fmt.Errorf("episode info missing")
 		}
 		if markAsPlayed {
 			_ = e.Update(map[string]any{
-				"status": 3,
+				"status": statusPlayed,
 			})
 		}
-		episodeList[i] = map[string]string{
-			"uuid":    e.UUID,
-			"podcast": e.PodcastUUID,
-		}
-	}
-	body := map[string]any{
-		"episodes": episodeList,
-		"archive":  true,
 	}
 
 	var wg sync.WaitGroup
@@ -89,7 +125,7 @@ func ArchiveEpisodes(episodes []*Episode, markAsPlayed bool) error {
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
-		archiveErr = PocketCastsRequest("/sync/update_episodes_archive", &body, nil)
+		archiveErr = updateArchive(episodes, true)
 	}()
 
 	go func() {
@@ -107,6 +143,84 @@ func ArchiveEpisodes(episodes []*Episode, markAsPlayed bool) error {
 	if queueErr != nil {
 		return queueErr
 	}
+	if markAsPlayed {
+		recordFinished(episodes...)
+	}
+	return nil
+}
+
+func UnarchiveEpisodes(episodes []*Episode) error {
+	if len(episodes) == 0 {
+		return fmt.Errorf("no episodes to unarchive")
+	}
+	return updateArchive(episodes, false)
+}
+
+func updateArchive(episodes []*Episode, archive bool) error {
+	episodeList := make([]map[string]string, len(episodes))
+	for i, e := range episodes {
+		if e.UUID == "" || e.PodcastUUID == "" {
+			return fmt.Errorf("episode info missing")
+		}
+		episodeList[i] = map[string]string{
+			"uuid":    e.UUID,
+			"podcast": e.PodcastUUID,
+		}
+	}
+	body := map[string]any{
+		"episodes": episodeList,
+		"archive":  archive,
+	}
+	if err := PocketCastsRequest("/sync/update_episodes_archive", &body, nil); err != nil {
+		return err
+	}
+	for _, e := range episodes {
+		e.Archived = archive
+	}
+	saveEpisodeStates(episodes)
+	return nil
+}
+
+func MarkEpisodesUnplayed(episodes []*Episode) error {
+	return updateEpisodes(episodes, func(e *Episode) map[string]any {
+		return map[string]any{"position": "0", "status": statusUnplayed}
+	})
+}
+
+func SetEpisodesPosition(episodes []*Episode, position int) error {
+	if position < 0 {
+		return fmt.Errorf("invalid position: %d", position)
+	}
+	status := statusInProgress
+	if position == 0 {
+		status = statusUnplayed
+	}
+	return updateEpisodes(episodes, func(e *Episode) map[string]any {
+		return map[string]any{"position": fmt.Sprintf("%d", position), "status": status}
+	})
+}
+
+func updateEpisodes(episodes []*Episode, body func(e *Episode) map[string]any) error {
+	if len(episodes) == 0 {
+		return fmt.Errorf("no episodes to update")
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, len(episodes))
+	for i, e := range episodes {
+		wg.Add(1)
+		go func(i int, e *Episode) {
+			defer wg.Done()
+			errs[i] = e.Update(body(e))
+		}(i, e)
+	}
+	wg.Wait()
+	// the episodes that failed are unchanged
+	saveEpisodeStates(episodes)
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -114,60 +228,87 @@ func (e *Episode) Archive(markAsPlayed bool) error {
 	return ArchiveEpisodes([]*Episode{e}, markAsPlayed)
 }
 
+func (e *Episode) Unarchive() error {
+	return UnarchiveEpisodes([]*Episode{e})
+}
+
 func (e *Episode) Update(body map[string]any) error {
 	// update position: {"position": "1234", "status": 2}
 	// mark as played: {"status": 3}
+	// mark as unplayed: {"position": "0", "status": 1}
 	if e.UUID == "" || e.PodcastUUID == "" {
 		return fmt.Errorf("episode info missing")
 	}
 	body["uuid"] = e.UUID
 	body["podcast"] = e.PodcastUUID
-	return PocketCastsRequest("/sync/update_episode", &body, nil)
-}
-
-func AddFeed(url string, pollUUID *string) (*Podcast, error) {
-	body := map[string]any{
-		"url":           url,
-		"poll_uuid":     pollUUID,
-		"public_option": "no",
+	if err := PocketCastsRequest("/sync/update_episode", &body, nil); err != nil {
+		return err
 	}
-	var response struct {
-		Status   string `json:"status"`
-		PollUUID string `json:"poll_uuid"`
-		Result   struct {
-			Podcast struct {
-				Name   string `json:"title"`
-				Author string `json:"author"`
-				Desc   string `json:"description"`
-				Image  string `json:"thumbnail_url"`
-				Link   string `json:"url"`
-				UUID   string `json:"uuid"`
-			} `json:"podcast"`
-		} `json:"result"`
-	}
-	if err := PocketCastsRequest("refresh.pocketcasts.com/author/add_feed_url", &body, &response); err != nil {
-		return nil, err
+	if status, ok := body["status"].(int); ok {
+		e.Status = status
 	}
-	switch response.Status {
-	case "poll":
-		return AddFeed(url, &response.PollUUID)
-	case "ok":
-		return &Podcast{
-			Name:   response.Result.Podcast.Name,
-			Author: response.Result.Podcast.Author,
-			Desc:   response.Result.Podcast.Desc,
-			Image:  response.Result.Podcast.Image,
-			Link:   response.Result.Podcast.Link,
-			UUID:   response.Result.Podcast.UUID,
-		}, nil
-	default:
-		return nil, fmt.Errorf("invalid feed URL")
+	if position, ok := body["position"].(string); ok {
+		e.PlayedUpTo, _ = strconv.Atoi(position)
+	}
+	return nil
+}
+
+const (
+	// Pocket Casts reads a new feed asynchronously; its status is polled
+	// this many times, this far apart
+	addFeedPolls        = 10
+	addFeedPollInterval = time.Second
+)
+
+// AddFeed registers a feed with Pocket Casts and returns its podcast
+func AddFeed(url string) (*Podcast, error) {
+	var pollUUID *string
+	for range addFeedPolls {
+		body := map[string]any{
+			"url":           url,
+			"poll_uuid":     pollUUID,
+			"public_option": "no",
+		}
+		var response struct {
+			Status   string `json:"status"`
+			PollUUID string `json:"poll_uuid"`
+			Result   struct {
+				Podcast struct {
+					Name   string `json:"title"`
+					Author string `json:"author"`
+					Desc   string `json:"description"`
+					Image  string `json:"thumbnail_url"`
+					Link   string `json:"url"`
+					UUID   string `json:"uuid"`
+				} `json:"podcast"`
+			} `json:"result"`
+		}
+		if err := PocketCastsRequest("refresh.pocketcasts.com/author/add_feed_url", &body, &response); err != nil {
+			return nil, err
+		}
+		switch response.Status {
+		case "poll":
+			pollUUID = &response.PollUUID
+			time.Sleep(addFeedPollInterval)
+		case "ok":
+			return &Podcast{
+				Name:   response.Result.Podcast.Name,
+				Author: response.Result.Podcast.Author,
+				Desc:   response.Result.Podcast.Desc,
+				Image:  response.Result.Podcast.Image,
+				Link:   response.Result.Podcast.Link,
+				UUID:   response.Result.Podcast.UUID,
+			}, nil
+		default:
+			return nil, fmt.Errorf("invalid feed URL")
+		}
 	}
+	return nil, fmt.Errorf("timed out adding feed: %s", url)
 }
 
 func (p *Podcast) Subscribe() error {
 	if p.UUID == "" && p.URL != "" {
-		if podcast, err := AddFeed(p.URL, nil); err != nil {
+		if podcast, err := AddFeed(p.URL); err != nil {
 			return err
 		} else {
 			p.Name = podcast.Name
@@ -198,6 +339,52 @@ func (p *Podcast) Unsubscribe() error {
 }
 
 func SearchPodcasts(term string) ([]*Podcast, error) {
+	podcasts, err := DiscoverPodcasts(term)
+	if err != nil {
+		return nil, err
+	}
+	file := getCachePath("search_results")
+	data, _ := json.Marshal(podcasts)
+	_ = writeCache(file, data)
+	return podcasts, nil
+}
+
+// SearchRemoteEpisodes searches the episodes of every podcast in the Pocket
+// Casts directory, subscribed or not. The episodes come without enclosure URL
+// and show notes, which are looked up when they are played or listed.
+func SearchRemoteEpisodes(term string) ([]*Episode, error) {
+	body := map[string]any{
+		"term": term,
+	}
+	var response struct {
+		Episodes []struct {
+			UUID        string    `json:"uuid"`
+			Title       string    `json:"title"`
+			Duration    int       `json:"duration"`
+			Date        time.Time `json:"published_at"`
+			PodcastUUID string    `json:"podcast_uuid"`
+			Podcast     string    `json:"podcast_title"`
+		} `json:"episodes"`
+	}
+	if err := PocketCastsRequest("podcast-api.pocketcasts.com/episode/search", &body, &response); err != nil {
+		return nil, err
+	}
+	episodes := make([]*Episode, len(response.Episodes))
+	for i, e := range response.Episodes {
+		episodes[i] = &Episode{
+			UUID:        e.UUID,
+			Title:       e.Title,
+			Duration:    e.Duration,
+			Date:        e.Date,
+			PodcastUUID: e.PodcastUUID,
+			Podcast:     e.Podcast,
+		}
+	}
+	return episodes, nil
+}
+
+// searchPodcasts is `SearchPodcasts` without keeping the results for `pcs`
+func searchPodcasts(term string) ([]*Podcast, error) {
 	body := map[string]any{
 		"term": term,
 	}
@@ -221,11 +408,8 @@ func SearchPodcasts(term string) ([]*Podcast, error) {
 			Desc:   podcast.Desc,
 			Link:   podcast.Link,
 			UUID:   podcast.UUID,
-			Image:  fmt.Sprintf("https://static.pocketcasts.com/discover/images/webp/200/%s.webp", podcast.UUID),
+			Image:  podcastImageURL(podcast.UUID),
 		}
 	}
-	file := getCachePath("search_results")
-	data, _ := json.Marshal(podcasts)
-	_ = writeCache(file, data)
 	return podcasts, nil
 }
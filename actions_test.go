@@ -119,6 +119,66 @@ func TestEpisode_Archive(t *testing.T) {
 	}
 }
 
+func TestEpisode_StateActions(t *testing.T) {
+	episode := &main.Episode{
+		UUID:        "2753add2-b0cb-4e42-b5e8-4656e89cb478",
+		PodcastUUID: "fe3d4040-10fa-0138-9f84-0acc26574db2",
+	}
+	tests := []struct {
+		name    string // description of this test case
+		action  string
+		episode *main.Episode
+		wantErr bool
+	}{
+		{
+			name:    "valid unarchive",
+			action:  "unarchive",
+			episode: episode,
+			wantErr: false,
+		},
+		{
+			name:    "valid mark as unplayed",
+			action:  "markAsUnplayed",
+			episode: episode,
+			wantErr: false,
+		},
+		{
+			name:    "valid set position",
+			action:  "setPosition",
+			episode: episode,
+			wantErr: false,
+		},
+		{
+			name:    "invalid unarchive",
+			action:  "unarchive",
+			episode: &main.Episode{UUID: "2753add2-b0cb-4e42-b5e8-4656e89cb478"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotErr error
+			switch tt.action {
+			case "unarchive":
+				gotErr = tt.episode.Unarchive()
+			case "markAsUnplayed":
+				gotErr = main.MarkEpisodesUnplayed([]*main.Episode{tt.episode})
+			case "setPosition":
+				gotErr = main.SetEpisodesPosition([]*main.Episode{tt.episode}, 90)
+			}
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("%s failed: %v", tt.name, gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatalf("%s succeeded unexpectedly", tt.name)
+			}
+		})
+	}
+}
+
 func TestSearchPodcasts(t *testing.T) {
 	tests := []struct {
 		name string // description of this test case
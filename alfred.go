@@ -51,6 +51,7 @@ type Item struct {
 		AltShift  *Mod `json:"alt+shift,omitempty"`
 		CtrlShift *Mod `json:"ctrl+shift,omitempty"`
 		CmdShift  *Mod `json:"cmd+shift,omitempty"`
+		CmdAlt    *Mod `json:"cmd+alt,omitempty"`
 	} `json:"mods"`
 }
 
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	artworkSize     = 256
+	maxArtworkBytes = 20 << 20
+	// a lock older than this was left behind by a process that died
+	artworkLockAge = time.Minute
+	// a failed download is not tried again before this
+	artworkRetryAge = 24 * time.Hour
+)
+
+var artworkDownloads singleflight.Group
+
+// downloadImage saves the image at `u` as a PNG of at most `artworkSize`
+// pixels. Concurrent downloads to the same path, within this process or
+// across processes, are done only once. Failures are recorded in
+// `path.failed` for `needsArtwork`.
+func downloadImage(u string, path string) error {
+	_, err, _ := artworkDownloads.Do(path, func() (any, error) {
+		err := fetchImage(u, path)
+		if err != nil {
+			_ = os.WriteFile(path+".failed", []byte(err.Error()), 0o644)
+		} else {
+			_ = os.Remove(path + ".failed")
+		}
+		return nil, err
+	})
+	return err
+}
+
+// needsArtwork tells if the image at `path` is missing and was not failed
+// to download recently
+func needsArtwork(path string) bool {
+	if _, err := os.Stat(path); err == nil {
+		return false
+	}
+	info, err := os.Stat(path + ".failed")
+	return err != nil || time.Since(info.ModTime()) > artworkRetryAge
+}
+
+func fetchImage(u string, path string) error {
+	lockfile := path + ".lock"
+	f, err := os.OpenFile(lockfile, os.O_CREATE|os.O_EXCL, 0o666)
+	if os.IsExist(err) {
+		if info, statErr := os.Stat(lockfile); statErr == nil && time.Since(info.ModTime()) > artworkLockAge {
+			_ = os.Remove(lockfile)
+			return fetchImage(u, path)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to create lock file: %v", err)
+	}
+	_ = f.Close()
+	defer func() { _ = os.Remove(lockfile) }()
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(u)
+	if err != nil {
+		return fmt.Errorf("error downloading image: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("image request failed with status: %d", resp.StatusCode)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" && !strings.HasPrefix(contentType, "image/") && !strings.HasPrefix(contentType, "application/octet-stream") {
+		return fmt.Errorf("not an image: %s", contentType)
+	}
+	img, _, err := image.Decode(io.LimitReader(resp.Body, maxArtworkBytes))
+	if err != nil {
+		return fmt.Errorf("error decoding image: %v", err)
+	}
+	return writePNG(downscale(img, artworkSize), path)
+}
+
+func downscale(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= size && b.Dy() <= size {
+		return img
+	}
+	w, h := size, size
+	if b.Dx() > b.Dy() {
+		h = max(b.Dy()*size/b.Dx(), 1)
+	} else {
+		w = max(b.Dx()*size/b.Dy(), 1)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+	return dst
+}
+
+// writePNG writes to a temporary file first, so that readers never see a
+// partially written image
+func writePNG(img image.Image, path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".artwork-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if err := png.Encode(tmp, img); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("error encoding image: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func podcastImageURL(uuid string) string {
+	return fmt.Sprintf("https://static.pocketcasts.com/discover/images/webp/200/%s.webp", uuid)
+}
+
+func (p *Podcast) CacheArtwork() {
+	file := getCachePath("artworks", p.UUID)
+	if p.Image != "" && needsArtwork(file) {
+		if err := downloadImage(p.Image, file); err != nil {
+			fmt.Fprintf(os.Stderr, "[%s]: artwork: %s\n", p.Name, err)
+		}
+	}
+}
+
+func (e *Episode) artworkPath() string {
+	return getCachePath("artworks", fmt.Sprintf("%s.%s", e.PodcastUUID, e.UUID))
+}
+
+// HasOwnArtwork tells if the episode has an image other than the podcast's
+func (e *Episode) HasOwnArtwork() bool {
+	return e.Image != "" && e.Image != podcastImageURL(e.PodcastUUID)
+}
+
+func (e *Episode) CacheArtwork() {
+	if !e.HasOwnArtwork() {
+		return
+	}
+	file := e.artworkPath()
+	if needsArtwork(file) {
+		if err := downloadImage(e.Image, file); err != nil {
+			fmt.Fprintf(os.Stderr, "[%s]: artwork: %s\n", e.Title, err)
+		}
+	}
+}
+
+// CacheArtworks downloads the missing artwork of all podcasts
+func CacheArtworks() {
+	sem := semaphore.NewWeighted(8)
+	var wg sync.WaitGroup
+	for _, p := range podcastMap {
+		wg.Add(1)
+		go func(p *Podcast) {
+			defer wg.Done()
+			if err := sem.Acquire(context.Background(), 1); err != nil {
+				return
+			}
+			defer sem.Release(1)
+			p.CacheArtwork()
+		}(p)
+	}
+	wg.Wait()
+}
+
+// the `podcastUuid/uuid` of the listed episodes whose artwork is missing
+var missingArtworks []string
+
+// fetchMissingArtworks downloads the artwork missing from a list in one
+// background process
+func fetchMissingArtworks() {
+	if len(missingArtworks) > 0 {
+		refreshInBackground([]string{"episode_artworks", strings.Join(missingArtworks, "\n")})
+	}
+}
+
+func (p *Podcast) CacheEpisodeArtworks() {
+	episodes := make([]*Episode, 0, len(p.EpisodeMap))
+	for _, e := range p.EpisodeMap {
+		episodes = append(episodes, e)
+	}
+	cacheEpisodeArtworks(episodes)
+}
+
+func cacheEpisodeArtworks(episodes []*Episode) {
+	sem := semaphore.NewWeighted(8)
+	var wg sync.WaitGroup
+	for _, e := range episodes {
+		wg.Add(1)
+		go func(e *Episode) {
+			defer wg.Done()
+			if err := sem.Acquire(context.Background(), 1); err != nil {
+				return
+			}
+			defer sem.Release(1)
+			e.CacheArtwork()
+		}(e)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+type Chapter struct {
+	Start float64 `json:"startTime"`
+	End   float64 `json:"endTime,omitempty"`
+	Title string  `json:"title"`
+	URL   string  `json:"url,omitempty"`
+	Image string  `json:"img,omitempty"`
+	TOC   *bool   `json:"toc,omitempty"`
+}
+
+func (e *Episode) GetChapters(force bool) ([]*Chapter, error) {
+	if e.UUID == "" || e.PodcastUUID == "" {
+		return nil, fmt.Errorf("episode info missing")
+	}
+	file := getCachePath("chapters", fmt.Sprintf("%s.%s", e.PodcastUUID, e.UUID))
+	maxAge := time.Duration(math.MaxInt64)
+	if force {
+		maxAge = 0
+	}
+	chapters := make([]*Chapter, 0)
+	if data, err := readCache(file, maxAge); err == nil {
+		// chapters are often added to the feed later, so an episode without
+		// them is checked again once its podcast's episodes expire
+		if err := json.Unmarshal(data, &chapters); err == nil && (len(chapters) > 0 || !chaptersExpired(file)) {
+			return chapters, nil
+		}
+	}
+	if e.URL == "" || e.ChaptersURL == "" {
+		p := &Podcast{UUID: e.PodcastUUID}
+		err := p.GetEpisodes(false)
+		if err == nil {
+			if _e, ok := p.EpisodeMap[e.UUID]; ok {
+				e.URL = _e.URL
+				e.ChaptersURL = _e.ChaptersURL
+			}
+		}
+		// without either there is nothing to read, which must not be cached
+		// as an episode without chapters
+		if e.URL == "" && e.ChaptersURL == "" {
+			if err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("episode not found: %s", e.UUID)
+		}
+	}
+
+	var err error
+	if e.ChaptersURL != "" {
+		chapters, err = fetchJSONChapters(e.ChaptersURL)
+	}
+	if len(chapters) == 0 && e.URL != "" {
+		chapters, err = fetchID3Chapters(e.URL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, _ := json.Marshal(chapters)
+	_ = writeCache(file, data)
+	return chapters, nil
+}
+
+func chaptersExpired(file string) bool {
+	info, err := os.Stat(file)
+	return err != nil || time.Since(info.ModTime()) > time.Duration(config.Cache.Episodes)
+}
+
+func fetchJSONChapters(u string) ([]*Chapter, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching chapters: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("chapters request failed with status: %d", resp.StatusCode)
+	}
+	var response struct {
+		Chapters []*Chapter `json:"chapters"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("error decoding chapters: %v", err)
+	}
+	chapters := make([]*Chapter, 0, len(response.Chapters))
+	for _, c := range response.Chapters {
+		// chapters with `toc: false` are meant for artwork and links only
+		if c.TOC == nil || *c.TOC {
+			chapters = append(chapters, c)
+		}
+	}
+	sort.SliceStable(chapters, func(i, j int) bool {
+		return chapters[i].Start < chapters[j].Start
+	})
+	return chapters, nil
+}
+
+// fetchID3Chapters reads only the ID3v2 tag at the start of the enclosure
+func fetchID3Chapters(u string) ([]*Chapter, error) {
+	header, err := fetchRange(u, 0, 9)
+	if err != nil {
+		return nil, err
+	}
+	if len(header) < 10 || string(header[:3]) != "ID3" {
+		return nil, nil
+	}
+	size := syncsafe(header[6:10])
+	tag, err := fetchRange(u, 0, int64(size)+9)
+	if err != nil {
+		return nil, err
+	}
+	return parseID3Chapters(tag)
+}
+
+func fetchRange(u string, from, to int64) ([]byte, error) {
+	const maxTagSize = 16 << 20
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", from, to))
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching enclosure: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("enclosure request failed with status: %d", resp.StatusCode)
+	}
+	// servers ignoring the range header send the whole file
+	n := min(to-from+1, maxTagSize)
+	return io.ReadAll(io.LimitReader(resp.Body, n))
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+type id3Frame struct {
+	id   string
+	data []byte
+}
+
+func readID3Frames(data []byte, version byte) []id3Frame {
+	frames := make([]id3Frame, 0)
+	for len(data) >= 10 {
+		id := string(data[:4])
+		if data[0] == 0 {
+			break
+		}
+		var size int
+		if version >= 4 {
+			size = syncsafe(data[4:8])
+		} else {
+			size = int(binary.BigEndian.Uint32(data[4:8]))
+		}
+		if size < 0 || 10+size > len(data) {
+			break
+		}
+		frames = append(frames, id3Frame{id: id, data: data[10 : 10+size]})
+		data = data[10+size:]
+	}
+	return frames
+}
+
+func parseID3Chapters(tag []byte) ([]*Chapter, error) {
+	if len(tag) < 10 || string(tag[:3]) != "ID3" {
+		return nil, fmt.Errorf("no ID3 tag found")
+	}
+	version := tag[3]
+	if version < 3 {
+		return nil, fmt.Errorf("unsupported ID3 version: 2.%d", version)
+	}
+	flags := tag[5]
+	end := min(10+syncsafe(tag[6:10]), len(tag))
+	body := tag[10:end]
+	if flags&0x40 != 0 && len(body) >= 4 {
+		// skip the extended header
+		extSize := int(binary.BigEndian.Uint32(body[:4]))
+		if version >= 4 {
+			extSize = syncsafe(body[:4])
+		} else {
+			extSize += 4
+		}
+		if extSize > len(body) {
+			return nil, fmt.Errorf("invalid ID3 extended header")
+		}
+		body = body[extSize:]
+	}
+
+	chapterMap := make(map[string]*Chapter)
+	var order []string
+	for _, f := range readID3Frames(body, version) {
+		switch f.id {
+		case "CHAP":
+			elementID, rest, ok := bytes.Cut(f.data, []byte{0})
+			if !ok || len(rest) < 16 {
+				continue
+			}
+			c := &Chapter{
+				Start: float64(binary.BigEndian.Uint32(rest[0:4])) / 1000,
+				End:   float64(binary.BigEndian.Uint32(rest[4:8])) / 1000,
+			}
+			for _, sub := range readID3Frames(rest[16:], version) {
+				switch sub.id {
+				case "TIT2":
+					c.Title = decodeID3Text(sub.data)
+				case "WXXX":
+					// encoding, description, then a latin-1 URL
+					if len(sub.data) > 1 {
+						if _, u, ok := bytes.Cut(sub.data[1:], []byte{0}); ok {
+							c.URL = strings.Trim(string(u), "\x00")
+						}
+					}
+				}
+			}
+			chapterMap[string(elementID)] = c
+		case "CTOC":
+			_, rest, ok := bytes.Cut(f.data, []byte{0})
+			if !ok || len(rest) < 2 || order != nil {
+				continue
+			}
+			count := int(rest[1])
+			rest = rest[2:]
+			for range count {
+				id, r, ok := bytes.Cut(rest, []byte{0})
+				if !ok {
+					break
+				}
+				order = append(order, string(id))
+				rest = r
+			}
+		}
+	}
+
+	chapters := make([]*Chapter, 0, len(chapterMap))
+	for _, id := range order {
+		if c, ok := chapterMap[id]; ok {
+			chapters = append(chapters, c)
+			delete(chapterMap, id)
+		}
+	}
+	rest := make([]*Chapter, 0, len(chapterMap))
+	for _, c := range chapterMap {
+		rest = append(rest, c)
+	}
+	sort.Slice(rest, func(i, j int) bool { return rest[i].Start < rest[j].Start })
+	if len(chapters) == 0 {
+		return rest, nil
+	}
+	return chapters, nil
+}
+
+func decodeID3Text(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	text := data[1:]
+	switch data[0] {
+	case 1, 2:
+		bigEndian := data[0] == 2
+		if len(text) >= 2 && text[0] == 0xfe && text[1] == 0xff {
+			bigEndian, text = true, text[2:]
+		} else if len(text) >= 2 && text[0] == 0xff && text[1] == 0xfe {
+			bigEndian, text = false, text[2:]
+		}
+		u := make([]uint16, 0, len(text)/2)
+		for i := 0; i+1 < len(text); i += 2 {
+			if bigEndian {
+				u = append(u, binary.BigEndian.Uint16(text[i:]))
+			} else {
+				u = append(u, binary.LittleEndian.Uint16(text[i:]))
+			}
+		}
+		return strings.TrimRight(string(utf16.Decode(u)), "\x00")
+	case 3:
+		return strings.TrimRight(string(text), "\x00")
+	default:
+		runes := make([]rune, len(text))
+		for i, b := range text {
+			runes[i] = rune(b)
+		}
+		return strings.TrimRight(string(runes), "\x00")
+	}
+}
+
+func chapterAt(chapters []*Chapter, position float64) int {
+	current := -1
+	for i, c := range chapters {
+		if c.Start <= position {
+			current = i
+		}
+	}
+	return current
+}
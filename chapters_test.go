@@ -0,0 +1,113 @@
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/twio142/alfred-podcasts"
+)
+
+func TestEpisode_GetChapters(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for receiver constructor.
+		episode *main.Episode
+		// Named input parameters for target function.
+		force   bool
+		wantErr bool
+	}{
+		{
+			name: "valid get chapters",
+			episode: &main.Episode{
+				UUID:        "8befa1d7-a5fe-4e3f-a337-04afffb5679d",
+				PodcastUUID: "c1c38690-d8f4-013e-7c78-02d8c28b0a65",
+			},
+			force:   true,
+			wantErr: false,
+		},
+		{
+			name: "valid read chapters cache",
+			episode: &main.Episode{
+				UUID:        "8befa1d7-a5fe-4e3f-a337-04afffb5679d",
+				PodcastUUID: "c1c38690-d8f4-013e-7c78-02d8c28b0a65",
+			},
+			force:   false,
+			wantErr: false,
+		},
+		{
+			name:    "invalid get chapters",
+			episode: &main.Episode{UUID: "8befa1d7-a5fe-4e3f-a337-04afffb5679d"},
+			force:   true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := tt.episode.GetChapters(tt.force)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("GetChapters() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("GetChapters() succeeded unexpectedly")
+			}
+			for _, c := range got {
+				fmt.Printf("%.0f\t%s\n", c.Start, c.Title)
+			}
+		})
+	}
+}
+
+func TestEpisode_GetChapters_Feed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chapters.json" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"chapters": [{"startTime": 90, "title": "News"}, {"startTime": 0, "title": "Intro"}, {"startTime": 30, "title": "Art", "toc": false}]}`)
+	}))
+	defer server.Close()
+	tests := []struct {
+		name        string // description of this test case
+		chaptersURL string
+		want        string
+		wantErr     bool
+	}{
+		{name: "chapters from the feed", chaptersURL: server.URL + "/chapters.json", want: "0 Intro, 90 News"},
+		{name: "chapters request failing", chaptersURL: server.URL + "/missing.json", wantErr: true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &main.Episode{
+				UUID:        fmt.Sprintf("chapters-test-%d", i),
+				PodcastUUID: "chapters-test",
+				URL:         server.URL + "/episode.mp3",
+				ChaptersURL: tt.chaptersURL,
+			}
+			got, gotErr := e.GetChapters(true)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("GetChapters() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("GetChapters() succeeded unexpectedly")
+			}
+			titles := ""
+			for j, c := range got {
+				if j > 0 {
+					titles += ", "
+				}
+				titles += fmt.Sprintf("%.0f %s", c.Start, c.Title)
+			}
+			if titles != tt.want {
+				t.Errorf("GetChapters() = %q, want %q", titles, tt.want)
+			}
+		})
+	}
+}
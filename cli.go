@@ -0,0 +1,492 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// exit codes of the command-line interface
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+var errUsage = errors.New("usage")
+
+type cliCommand struct {
+	name  string
+	usage string
+	run   func(args []string, out io.Writer) error
+}
+
+var cliCommands []*cliCommand
+
+func init() {
+	cliCommands = []*cliCommand{
+		{"list", "list [--json]\n\tList subscribed podcasts", cliList},
+		{"episodes", "episodes [--json] [--sort newest|oldest|longest|unplayed] [--limit n] [--archived] <podcast>\n\tList episodes of a podcast, given by name or UUID", cliEpisodes},
+		{"queue", "queue [--json]\n\tList Up Next\nqueue add [--next] <podcast-uuid>/<episode-uuid>...\n\tAdd episodes to Up Next\nqueue remove <podcast-uuid>/<episode-uuid>...\n\tRemove episodes from Up Next", cliQueue},
+		{"archive", "archive [--played] <podcast-uuid>/<episode-uuid>...\n\tArchive episodes, optionally marking them as played", cliArchive},
+		{"sync", "sync\n\tSync the player's playback state to Pocket Casts", cliSync},
+		{"search", "search [--json] [--limit n] [--remote] <query>\n\tSearch cached episodes, with --remote also all of Pocket Casts", cliSearch},
+		{"refresh", "refresh [--podcast uuid] [allPodcasts|podcast|up_next|new_releases|history|filters|stats]\n\tRefresh the cache", cliRefresh},
+		{"serve", "serve [--addr 127.0.0.1:8750]\n\tServe the library as a JSON API on localhost", cliServe},
+		{"completion", "completion bash|zsh|fish\n\tPrint a shell completion script", cliCompletion},
+	}
+}
+
+// isCLI tells if the program runs from a shell rather than from Alfred
+func isCLI() bool {
+	return os.Getenv("alfred_workflow_cache") == "" && len(os.Args) > 1
+}
+
+// setupCLI shares the cache and the token with the workflow
+func setupCLI() {
+	if dir := os.Getenv("PODCASTS_CACHE"); dir != "" {
+		cacheDir = dir
+	} else if dir, err := os.UserCacheDir(); err == nil {
+		cacheDir = filepath.Join(dir, "com.runningwithcrayons.Alfred", "Workflow Data", "com.twio142.podcasts")
+	}
+	// for background refreshes
+	_ = os.Setenv("alfred_workflow_cache", cacheDir)
+	if executable, err := os.Executable(); err == nil {
+		if executable, err = filepath.EvalSymlinks(executable); err == nil {
+			_ = os.Chdir(filepath.Dir(executable))
+		}
+	}
+}
+
+func cliUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags] [args]\n\nCommands:\n", filepath.Base(os.Args[0]))
+	for _, c := range cliCommands {
+		for line := range strings.Lines(c.usage) {
+			fmt.Fprintf(w, "  %s", line)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func RunCLI(args []string, out io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		cliUsage(out)
+		return exitOK
+	}
+	for _, c := range cliCommands {
+		if c.name != args[0] {
+			continue
+		}
+		err := c.run(args[1:], out)
+		switch {
+		case err == nil:
+			return exitOK
+		case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+			fmt.Fprintf(os.Stderr, "Usage: %s %s\n", filepath.Base(os.Args[0]), c.usage)
+			return exitUsage
+		default:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", args[0])
+	cliUsage(os.Stderr)
+	return exitUsage
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// parseFlags allows flags after positional arguments
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, fmt.Errorf("%w: %v", errUsage, err)
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func printJSON(out io.Writer, v any) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// withoutShowNotes keeps the output of episode lists readable
+func withoutShowNotes(episodes []*Episode) []*Episode {
+	list := make([]*Episode, len(episodes))
+	for i, e := range episodes {
+		_e := *e
+		_e.ShowNotes = ""
+		list[i] = &_e
+	}
+	return list
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+func episodeStatus(e *Episode) string {
+	switch {
+	case e.IsPlayed():
+		return "played"
+	case e.PlayedUpTo > 0:
+		return fmt.Sprintf("%d%%", e.PlayedUpTo*100/max(e.Duration, 1))
+	case e.Archived:
+		return "archived"
+	}
+	return ""
+}
+
+func printEpisodes(out io.Writer, episodes []*Episode, asJSON bool) error {
+	if asJSON {
+		return printJSON(out, withoutShowNotes(episodes))
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DATE\tPODCAST\tTITLE\tDURATION\tSTATUS\tID")
+	for _, e := range episodes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s/%s\n", e.Date.Format("2006-01-02"), truncate(e.Podcast, 24),
+			truncate(e.Title, 60), formatDuration(e.Duration), episodeStatus(e), e.PodcastUUID, e.UUID)
+	}
+	return w.Flush()
+}
+
+// parseEpisodeRefs reads `podcast-uuid/episode-uuid` arguments
+func parseEpisodeRefs(args []string) ([]*Episode, error) {
+	if len(args) == 0 {
+		return nil, errUsage
+	}
+	pairs := make([][2]string, 0, len(args))
+	for _, arg := range args {
+		podcastUUID, uuid, ok := strings.Cut(arg, "/")
+		if !ok || podcastUUID == "" || uuid == "" {
+			return nil, fmt.Errorf("invalid episode: %s, expected <podcast-uuid>/<episode-uuid>", arg)
+		}
+		pairs = append(pairs, [2]string{podcastUUID, uuid})
+	}
+	return resolveEpisodes(pairs), nil
+}
+
+// cliEpisodeRefs resolves the arguments of the commands that change episodes,
+// which fail for episodes missing from the cache
+func cliEpisodeRefs(args []string) ([]*Episode, error) {
+	episodes, err := parseEpisodeRefs(args)
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range episodes {
+		if e.URL == "" {
+			return nil, fmt.Errorf("episode not found: %s", args[i])
+		}
+	}
+	return episodes, nil
+}
+
+func cliList(args []string, out io.Writer) error {
+	fs := newFlagSet("list")
+	asJSON := fs.Bool("json", false, "")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := GetPodcastList(false); err != nil {
+		return err
+	}
+	podcasts := make([]*Podcast, 0, len(podcastMap))
+	for _, p := range podcastMap {
+		podcasts = append(podcasts, p)
+	}
+	sort.Slice(podcasts, func(i, j int) bool {
+		return podcasts[i].LastUpdated.After(podcasts[j].LastUpdated)
+	})
+	if *asJSON {
+		return printJSON(out, podcasts)
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "UPDATED\tNAME\tAUTHOR\tUUID")
+	for _, p := range podcasts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.LastUpdated.Format("2006-01-02"), truncate(p.Name, 40), truncate(p.Author, 30), p.UUID)
+	}
+	return w.Flush()
+}
+
+// findPodcast matches a UUID, then a name, then part of a name
+func findPodcast(query string) (*Podcast, error) {
+	if err := GetPodcastList(false); err != nil {
+		return nil, err
+	}
+	if p, ok := podcastMap[query]; ok {
+		return p, nil
+	}
+	q := strings.ToLower(query)
+	matches := make([]*Podcast, 0)
+	for _, p := range podcastMap {
+		if strings.ToLower(p.Name) == q {
+			return p, nil
+		}
+		if strings.Contains(strings.ToLower(p.Name), q) {
+			matches = append(matches, p)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("podcast not found: %s", query)
+	case 1:
+		return matches[0], nil
+	}
+	names := make([]string, len(matches))
+	for i, p := range matches {
+		names[i] = p.Name
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("ambiguous podcast %q: %s", query, strings.Join(names, ", "))
+}
+
+func cliEpisodes(args []string, out io.Writer) error {
+	fs := newFlagSet("episodes")
+	asJSON := fs.Bool("json", false, "")
+	mode := fs.String("sort", "newest", "")
+	limit := fs.Int("limit", config.Episodes.PageSize, "")
+	archived := fs.Bool("archived", false, "")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return errUsage
+	}
+	p, err := findPodcast(strings.Join(positional, " "))
+	if err != nil {
+		return err
+	}
+	if err := p.GetEpisodes(false); err != nil {
+		return err
+	}
+	episodes := make([]*Episode, 0)
+	for _, e := range p.FilterEpisodes("", *mode) {
+		if *archived || !e.Archived {
+			episodes = append(episodes, e)
+		}
+	}
+	if *limit > 0 && len(episodes) > *limit {
+		episodes = episodes[:*limit]
+	}
+	return printEpisodes(out, episodes, *asJSON)
+}
+
+func cliQueue(args []string, out io.Writer) error {
+	if len(args) > 0 && (args[0] == "add" || args[0] == "remove") {
+		fs := newFlagSet("queue " + args[0])
+		next := fs.Bool("next", false, "")
+		positional, err := parseFlags(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		episodes, err := cliEpisodeRefs(positional)
+		if err != nil {
+			return err
+		}
+		if args[0] == "remove" {
+			_, err := RemoveEpisodesFromQueue(episodes)
+			return err
+		}
+		action := "play_last"
+		if *next {
+			action = "play_next"
+		}
+		for _, e := range episodes {
+			if _, err := e.AddToQueue(action); err != nil {
+				return fmt.Errorf("%s: %v", e.UUID, err)
+			}
+		}
+		return nil
+	}
+	fs := newFlagSet("queue")
+	asJSON := fs.Bool("json", false, "")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return errUsage
+	}
+	episodes, err := GetUpNext(false)
+	if err != nil {
+		return err
+	}
+	return printEpisodes(out, episodes, *asJSON)
+}
+
+func cliArchive(args []string, out io.Writer) error {
+	fs := newFlagSet("archive")
+	played := fs.Bool("played", false, "")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	episodes, err := cliEpisodeRefs(positional)
+	if err != nil {
+		return err
+	}
+	return ArchiveEpisodes(episodes, *played)
+}
+
+func cliSync(args []string, out io.Writer) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+	return SyncPlaylist()
+}
+
+func cliSearch(args []string, out io.Writer) error {
+	fs := newFlagSet("search")
+	asJSON := fs.Bool("json", false, "")
+	limit := fs.Int("limit", 50, "")
+	remote := fs.Bool("remote", false, "")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return errUsage
+	}
+	search := SearchEpisodes
+	if *remote {
+		search = SearchAllEpisodes
+	}
+	results, err := search(strings.Join(positional, " "))
+	if err != nil {
+		return err
+	}
+	if *limit > 0 && len(results) > *limit {
+		results = results[:*limit]
+	}
+	episodes := make([]*Episode, len(results))
+	for i, r := range results {
+		episodes[i] = r.Episode
+	}
+	return printEpisodes(out, episodes, *asJSON)
+}
+
+func cliRefresh(args []string, out io.Writer) error {
+	fs := newFlagSet("refresh")
+	podcastUUID := fs.String("podcast", "", "")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	target := "allPodcasts"
+	if len(positional) > 1 {
+		return errUsage
+	} else if len(positional) == 1 {
+		target = positional[0]
+	}
+	if *podcastUUID != "" && len(positional) == 0 {
+		target = "podcast"
+	}
+	if (target == "podcast") != (*podcastUUID != "") {
+		return errUsage
+	}
+	start := time.Now()
+	if err := refreshCache([]string{target, *podcastUUID}); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Refreshed %s in %s\n", target, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func cliServe(args []string, out io.Writer) error {
+	fs := newFlagSet("serve")
+	addr := fs.String("addr", defaultServeAddr, "")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return errUsage
+	}
+	return Serve(*addr, out)
+}
+
+func cliCompletion(args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	name := filepath.Base(os.Args[0])
+	commands := make([]string, len(cliCommands))
+	for i, c := range cliCommands {
+		commands[i] = c.name
+	}
+	words := strings.Join(commands, " ")
+	flags := []string{"json", "sort", "limit", "archived", "next", "played", "remote", "addr"}
+	longFlags := "--" + strings.Join(flags, " --")
+	switch args[0] {
+	case "bash":
+		fmt.Fprintf(out, `_%[1]s() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		COMPREPLY=($(compgen -W "%[2]s" -- "$cur"))
+	elif [ "${COMP_WORDS[1]}" = queue ] && [ "$COMP_CWORD" -eq 2 ]; then
+		COMPREPLY=($(compgen -W "add remove --json" -- "$cur"))
+	elif [ "${COMP_WORDS[1]}" = refresh ]; then
+		COMPREPLY=($(compgen -W "allPodcasts podcast up_next new_releases history filters stats --podcast" -- "$cur"))
+	elif [ "${COMP_WORDS[1]}" = completion ]; then
+		COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur"))
+	else
+		COMPREPLY=($(compgen -W "%[3]s" -- "$cur"))
+	fi
+}
+complete -F _%[1]s %[1]s
+`, name, words, longFlags)
+	case "zsh":
+		fmt.Fprintf(out, `#compdef %[1]s
+_%[1]s() {
+	if (( CURRENT == 2 )); then
+		compadd %[2]s
+	elif [[ $words[2] == queue && CURRENT == 3 ]]; then
+		compadd add remove --json
+	elif [[ $words[2] == refresh ]]; then
+		compadd allPodcasts podcast up_next new_releases history filters stats --podcast
+	elif [[ $words[2] == completion ]]; then
+		compadd bash zsh fish
+	else
+		compadd -- %[3]s
+	fi
+}
+compdef _%[1]s %[1]s
+`, name, words, longFlags)
+	case "fish":
+		fmt.Fprintf(out, "complete -c %s -f -n __fish_use_subcommand -a '%s'\n", name, words)
+		fmt.Fprintf(out, "complete -c %s -f -n '__fish_seen_subcommand_from queue' -a 'add remove'\n", name)
+		fmt.Fprintf(out, "complete -c %s -f -n '__fish_seen_subcommand_from refresh' -a 'allPodcasts podcast up_next new_releases history filters stats'\n", name)
+		fmt.Fprintf(out, "complete -c %s -f -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'\n", name)
+		fmt.Fprintf(out, "complete -c %s -n '__fish_seen_subcommand_from refresh' -l podcast\n", name)
+		for _, f := range flags {
+			fmt.Fprintf(out, "complete -c %s -l %s\n", name, f)
+		}
+	default:
+		return errUsage
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/twio142/alfred-podcasts"
+)
+
+func TestRunCLI(t *testing.T) {
+	tests := []struct {
+		name     string // description of this test case
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{name: "help", args: []string{"help"}, wantCode: 0, wantOut: "Commands:"},
+		{name: "unknown command", args: []string{"bogus"}, wantCode: 2},
+		{name: "missing podcast", args: []string{"episodes", "--json"}, wantCode: 2},
+		{name: "invalid flag", args: []string{"queue", "--bogus"}, wantCode: 2},
+		{name: "invalid episode", args: []string{"archive", "not-an-episode"}, wantCode: 1},
+		{name: "bash completion", args: []string{"completion", "bash"}, wantCode: 0, wantOut: "complete -F"},
+		{name: "fish completion", args: []string{"completion", "fish"}, wantCode: 0, wantOut: "__fish_use_subcommand"},
+		{name: "fish completion flags", args: []string{"completion", "fish"}, wantCode: 0, wantOut: "-l remote"},
+		{name: "zsh completion flags", args: []string{"completion", "zsh"}, wantCode: 0, wantOut: "--remote --addr"},
+		{name: "refresh podcast of other target", args: []string{"refresh", "up_next", "--podcast", "abc"}, wantCode: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if got := main.RunCLI(tt.args, &out); got != tt.wantCode {
+				t.Errorf("RunCLI() = %v, want %v", got, tt.wantCode)
+			}
+			if !strings.Contains(out.String(), tt.wantOut) {
+				t.Errorf("RunCLI() output does not contain %q", tt.wantOut)
+			}
+		})
+	}
+}
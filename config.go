@@ -0,0 +1,436 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Duration reads Go durations plus days, e.g. `60d` or `1d12h`
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := parseConfigDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d Duration) String() string {
+	t := time.Duration(d)
+	if t > 0 && t%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", t/(24*time.Hour))
+	}
+	s := t.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+func parseConfigDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	var days time.Duration
+	if i := strings.Index(s, "d"); i > 0 {
+		n, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %s", s)
+		}
+		days, s = time.Duration(n)*24*time.Hour, s[i+1:]
+		if s == "" {
+			return days, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+	return days + d, nil
+}
+
+type Config struct {
+	Cache struct {
+		PodcastList Duration `toml:"podcast_list" help:"How long the podcast list is cached"`
+		Episodes    Duration `toml:"episodes" help:"How long the episodes of a podcast are cached"`
+		UpNext      Duration `toml:"up_next" help:"How long Up Next is cached"`
+		Lists       Duration `toml:"lists" help:"How long new releases, history, filters and stats are cached"`
+		Retention   Duration `toml:"retention" help:"How long show notes and transcripts are kept"`
+		Discover    Duration `toml:"discover" help:"How long the discover lists and categories are cached"`
+	} `toml:"cache"`
+	Player struct {
+		Socket             string `toml:"socket" help:"The IPC socket of IINA or mpv"`
+		SyncSharedPosition bool   `toml:"sync_shared_position" help:"Save the start time of a shared link to Pocket Casts when playing it"`
+	} `toml:"player"`
+	Discover struct {
+		Region string `toml:"region" help:"Region of the discover charts, e.g. us or gb; empty for Pocket Casts' default"`
+	} `toml:"discover"`
+	PodcastIndex struct {
+		Key     string `toml:"key" help:"Podcast Index API key; searched alongside Pocket Casts when set"`
+		Secret  string `toml:"secret" help:"Podcast Index API secret"`
+		BaseURL string `toml:"base_url" help:"Podcast Index API endpoint"`
+	} `toml:"podcast_index"`
+	Episodes struct {
+		PageSize int `toml:"page_size" help:"Episodes shown per page"`
+	} `toml:"episodes"`
+	DownloadDir string              `toml:"download_dir" help:"Where downloaded episodes are kept"`
+	Notify      []string            `toml:"notify" help:"Podcasts to notify about new episodes, or \"*\""`
+	Playlists   map[string]string   `toml:"playlists" help:"Smart playlists, name = rule"`
+	Tags        map[string][]string `toml:"tags" help:"Podcast tags, tag = [podcast names]"`
+	Priorities  map[string]int      `toml:"priorities" help:"Podcast priorities for filling the queue"`
+	sources     map[string]string
+}
+
+// ConfigError is an invalid setting
+type ConfigError struct {
+	Key string
+	Msg string
+}
+
+func (e *ConfigError) Error() string {
+	return e.Key + " " + e.Msg
+}
+
+func defaultConfig() *Config {
+	c := &Config{}
+	c.Cache.PodcastList = Duration(24 * time.Hour)
+	c.Cache.Episodes = Duration(12 * time.Hour)
+	c.Cache.UpNext = Duration(30 * time.Minute)
+	c.Cache.Lists = Duration(12 * time.Hour)
+	c.Cache.Retention = Duration(60 * 24 * time.Hour)
+	c.Cache.Discover = Duration(3 * 24 * time.Hour)
+	c.Player.Socket = "/tmp/iina.sock"
+	c.Episodes.PageSize = 30
+	c.PodcastIndex.BaseURL = defaultPodcastIndexURL
+	return c
+}
+
+var config = defaultConfig()
+
+// configPath is `config.toml` in the workflow data directory, unless
+// `PODCASTS_CONFIG` is set
+func configPath() string {
+	if file := os.Getenv("PODCASTS_CONFIG"); file != "" {
+		return file
+	}
+	dir := os.Getenv("alfred_workflow_data")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, "Library", "Application Support", "Alfred", "Workflow Data", "com.twio142.podcasts")
+	}
+	return filepath.Join(dir, "config.toml")
+}
+
+// Setting is a scalar config value addressed by its dotted key
+type Setting struct {
+	Key    string
+	Help   string
+	Source string // default, file or env
+	value  reflect.Value
+}
+
+func (s *Setting) Value() string {
+	switch v := s.value.Interface().(type) {
+	case Duration:
+		return v.String()
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// EnvName is the variable overriding the setting, e.g. `PODCASTS_CACHE_UP_NEXT`
+func (s *Setting) EnvName() string {
+	return "PODCASTS_" + strings.ToUpper(strings.ReplaceAll(s.Key, ".", "_"))
+}
+
+func (s *Setting) Set(value string) error {
+	switch s.value.Interface().(type) {
+	case Duration:
+		var d Duration
+		if err := d.UnmarshalText([]byte(value)); err != nil {
+			return err
+		}
+		s.value.Set(reflect.ValueOf(d))
+	case int:
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("not a number: %s", value)
+		}
+		s.value.SetInt(int64(n))
+	case bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("not true or false: %s", value)
+		}
+		s.value.SetBool(b)
+	case string:
+		s.value.SetString(value)
+	default:
+		return fmt.Errorf("%s can only be edited in the config file", s.Key)
+	}
+	return nil
+}
+
+// Settings lists the scalar settings of `c`, sorted by key
+func (c *Config) Settings() []*Setting {
+	settings := make([]*Setting, 0)
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := range t.NumField() {
+			f := t.Field(i)
+			key := prefix + strings.Split(f.Tag.Get("toml"), ",")[0]
+			switch f.Type.Kind() {
+			case reflect.Struct:
+				walk(v.Field(i), key+".")
+			case reflect.Map, reflect.Slice:
+				continue
+			default:
+				source := c.sources[key]
+				if source == "" {
+					source = "default"
+				}
+				settings = append(settings, &Setting{Key: key, Help: f.Tag.Get("help"), Source: source, value: v.Field(i)})
+			}
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "")
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	return settings
+}
+
+func (c *Config) Setting(key string) *Setting {
+	for _, s := range c.Settings() {
+		if s.Key == key {
+			return s
+		}
+	}
+	return nil
+}
+
+func (c *Config) Validate() []*ConfigError {
+	errs := make([]*ConfigError, 0)
+	for _, s := range c.Settings() {
+		if d, ok := s.value.Interface().(Duration); ok && d < 0 {
+			errs = append(errs, &ConfigError{s.Key, "must not be negative"})
+		}
+	}
+	if c.Cache.Retention < Duration(24*time.Hour) {
+		errs = append(errs, &ConfigError{"cache.retention", "must be at least 1d"})
+	}
+	if !filepath.IsAbs(c.Player.Socket) {
+		errs = append(errs, &ConfigError{"player.socket", "must be an absolute path"})
+	}
+	if u, err := url.Parse(c.PodcastIndex.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, &ConfigError{"podcast_index.base_url", "must be an http(s) URL"})
+	}
+	if c.Episodes.PageSize < 5 || c.Episodes.PageSize > 200 {
+		errs = append(errs, &ConfigError{"episodes.page_size", "must be between 5 and 200"})
+	}
+	for name, rule := range c.Playlists {
+		if _, err := ParseRule(rule); err != nil {
+			errs = append(errs, &ConfigError{"playlists." + name, "is invalid: " + err.Error()})
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Key < errs[j].Key })
+	return errs
+}
+
+// LoadConfig reads the config file and the environment over the defaults.
+// Invalid values are reported and left at their defaults.
+func LoadConfig() (*Config, []error) {
+	c := defaultConfig()
+	errs := make([]error, 0)
+	file := configPath()
+	fromFile := make(map[string]bool)
+	if _, err := os.Stat(file); err == nil {
+		md, err := toml.DecodeFile(file, c)
+		if err != nil {
+			c = defaultConfig()
+			errs = append(errs, fmt.Errorf("%s: %v", filepath.Base(file), err))
+		}
+		for _, key := range md.Undecoded() {
+			errs = append(errs, fmt.Errorf("unknown setting: %s", key))
+		}
+		for _, key := range md.Keys() {
+			fromFile[key.String()] = true
+		}
+	}
+	c.sources = make(map[string]string)
+	for _, s := range c.Settings() {
+		if fromFile[s.Key] {
+			c.sources[s.Key] = "file"
+		}
+		if value, ok := os.LookupEnv(s.EnvName()); ok {
+			if err := s.Set(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", s.EnvName(), err))
+			} else {
+				c.sources[s.Key] = "env"
+			}
+		}
+	}
+	// invalid values fall back to their defaults
+	defaults := defaultConfig()
+	for _, err := range c.Validate() {
+		errs = append(errs, err)
+		if s, d := c.Setting(err.Key), defaults.Setting(err.Key); s != nil && d != nil {
+			s.value.Set(d.value)
+			delete(c.sources, err.Key)
+		} else if name, ok := strings.CutPrefix(err.Key, "playlists."); ok {
+			delete(c.Playlists, name)
+		}
+	}
+	return c, errs
+}
+
+// SetConfigValue writes a single setting to the config file
+func SetConfigValue(key, value string) error {
+	c := defaultConfig()
+	s := c.Setting(key)
+	if s == nil {
+		return fmt.Errorf("unknown setting: %s", key)
+	}
+	if err := s.Set(value); err != nil {
+		return err
+	}
+	for _, err := range c.Validate() {
+		if err.Key == key {
+			return err
+		}
+	}
+	v := s.value.Interface()
+	if d, ok := v.(Duration); ok {
+		v = d.String()
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(map[string]any{"v": v}); err != nil {
+		return err
+	}
+	encoded := strings.TrimSpace(strings.TrimPrefix(buf.String(), "v = "))
+	return editConfig(key, &encoded)
+}
+
+// ResetConfigValue removes a setting from the config file
+func ResetConfigValue(key string) error {
+	return editConfig(key, nil)
+}
+
+var (
+	configTableRe = regexp.MustCompile(`^\s*\[\s*([^\[\]]+?)\s*\]\s*(#.*)?$`)
+	configKeyRe   = regexp.MustCompile(`^\s*("[^"]*"|'[^']*'|[A-Za-z0-9_-]+)\s*=\s*(.*)$`)
+)
+
+// editConfig sets a key of the config file to an encoded value, or removes it
+// when value is nil, touching only that line so comments and order are kept
+func editConfig(key string, value *string) error {
+	file := configPath()
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) && value == nil {
+		return nil
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	table, name := "", key
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		table, name = key[:i], key[i+1:]
+	}
+	var lines []string
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+	current, found, end, inTable := "", -1, 0, table == ""
+	for i, line := range lines {
+		if m := configTableRe.FindStringSubmatch(line); m != nil {
+			current = strings.Trim(m[1], `"'`)
+			if current == table {
+				end, inTable = i+1, true
+			}
+			continue
+		} else if strings.HasPrefix(strings.TrimSpace(line), "[") {
+			current = "\x00" // array of tables
+			continue
+		}
+		if current != table {
+			continue
+		}
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			end = i + 1
+		}
+		if m := configKeyRe.FindStringSubmatch(line); m != nil && strings.Trim(m[1], `"'`) == name {
+			found = i
+		}
+	}
+
+	switch {
+	case found >= 0 && value == nil:
+		lines = append(lines[:found], lines[found+1:]...)
+	case found >= 0:
+		m := configKeyRe.FindStringSubmatch(lines[found])
+		rest := strings.TrimRight(m[2][tomlValueEnd(m[2]):], " \t")
+		lines[found] = lines[found][:len(lines[found])-len(m[2])] + *value + rest
+	case value == nil:
+		return nil
+	case inTable:
+		lines = append(lines[:end], append([]string{name + " = " + *value}, lines[end:]...)...)
+	default:
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "["+table+"]", name+" = "+*value)
+	}
+
+	content := strings.Join(lines, "\n") + "\n"
+	if _, err := toml.Decode(content, &map[string]any{}); err != nil {
+		return fmt.Errorf("%s: %v", filepath.Base(file), err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, []byte(content), 0o644)
+}
+
+// tomlValueEnd returns where an inline value ends, before any trailing comment
+func tomlValueEnd(s string) int {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+			} else if s[i] == '"' {
+				return i + 1
+			}
+		}
+		return len(s)
+	case strings.HasPrefix(s, "'"):
+		if i := strings.Index(s[1:], "'"); i >= 0 {
+			return i + 2
+		}
+		return len(s)
+	}
+	if i := strings.Index(s, "#"); i >= 0 {
+		return i
+	}
+	return len(s)
+}
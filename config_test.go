@@ -0,0 +1,174 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/twio142/alfred-podcasts"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name       string // description of this test case
+		file       string
+		env        map[string]string
+		key        string
+		wantValue  string
+		wantSource string
+		wantErr    string
+	}{
+		{name: "default", key: "cache.up_next", wantValue: "30m", wantSource: "default"},
+		{name: "days", file: "[cache]\nretention = \"30d\"\n", key: "cache.retention", wantValue: "30d", wantSource: "file"},
+		{name: "mixed duration", file: "[cache]\nepisodes = \"1d12h\"\n", key: "cache.episodes", wantValue: "36h", wantSource: "file"},
+		{name: "env override", file: "[episodes]\npage_size = 50\n", env: map[string]string{"PODCASTS_EPISODES_PAGE_SIZE": "20"}, key: "episodes.page_size", wantValue: "20", wantSource: "env"},
+		{name: "out of range", file: "[episodes]\npage_size = 1000\n", key: "episodes.page_size", wantValue: "30", wantSource: "default", wantErr: "episodes.page_size must be between 5 and 200"},
+		{name: "relative socket", file: "[player]\nsocket = \"iina.sock\"\n", key: "player.socket", wantValue: "/tmp/iina.sock", wantSource: "default", wantErr: "player.socket must be an absolute path"},
+		{name: "bool", env: map[string]string{"PODCASTS_PLAYER_SYNC_SHARED_POSITION": "true"}, key: "player.sync_shared_position", wantValue: "true", wantSource: "env"},
+		{name: "bad bool", env: map[string]string{"PODCASTS_PLAYER_SYNC_SHARED_POSITION": "maybe"}, key: "player.sync_shared_position", wantValue: "false", wantSource: "default", wantErr: "not true or false"},
+		{name: "podcast index stand-in", env: map[string]string{"PODCASTS_PODCAST_INDEX_BASE_URL": "http://127.0.0.1:8080/api/1.0"}, key: "podcast_index.base_url", wantValue: "http://127.0.0.1:8080/api/1.0", wantSource: "env"},
+		{name: "bad base url", file: "[podcast_index]\nbase_url = \"api.podcastindex.org\"\n", key: "podcast_index.base_url", wantValue: "https://api.podcastindex.org/api/1.0", wantSource: "default", wantErr: "podcast_index.base_url must be an http(s) URL"},
+		{name: "bad duration", env: map[string]string{"PODCASTS_CACHE_LISTS": "soon"}, key: "cache.lists", wantValue: "12h", wantSource: "default", wantErr: "invalid duration"},
+		{name: "unknown key", file: "[cache]\nforever = true\n", key: "cache.lists", wantValue: "12h", wantSource: "default", wantErr: "unknown setting: cache.forever"},
+		{name: "invalid playlist", file: "[playlists]\nBroken = \"duration >\"\n", key: "cache.lists", wantValue: "12h", wantSource: "default", wantErr: "playlists.Broken is invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "config.toml")
+			if tt.file != "" {
+				if err := os.WriteFile(file, []byte(tt.file), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Setenv("PODCASTS_CONFIG", file)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			c, errs := main.LoadConfig()
+			s := c.Setting(tt.key)
+			if s == nil {
+				t.Fatalf("Setting(%q) = nil", tt.key)
+			}
+			if got := s.Value(); got != tt.wantValue {
+				t.Errorf("Value() = %v, want %v", got, tt.wantValue)
+			}
+			if s.Source != tt.wantSource {
+				t.Errorf("Source = %v, want %v", s.Source, tt.wantSource)
+			}
+			var msgs []string
+			for _, err := range errs {
+				msgs = append(msgs, err.Error())
+			}
+			got := strings.Join(msgs, "; ")
+			if tt.wantErr == "" && got != "" || !strings.Contains(got, tt.wantErr) {
+				t.Errorf("LoadConfig() errors = %q, want %q", got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSetConfigValue(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(file, []byte("[playlists]\nShort = \"duration < 20\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PODCASTS_CONFIG", file)
+
+	if err := main.SetConfigValue("cache.up_next", "1h"); err != nil {
+		t.Fatalf("SetConfigValue() error = %v", err)
+	}
+	if err := main.SetConfigValue("episodes.page_size", "2"); err == nil {
+		t.Error("SetConfigValue() accepted an invalid page size")
+	}
+	if err := main.SetConfigValue("cache.bogus", "1h"); err == nil {
+		t.Error("SetConfigValue() accepted an unknown key")
+	}
+	c, errs := main.LoadConfig()
+	if len(errs) > 0 {
+		t.Fatalf("LoadConfig() errors = %v", errs)
+	}
+	if got := time.Duration(c.Cache.UpNext); got != time.Hour {
+		t.Errorf("cache.up_next = %v, want 1h", got)
+	}
+	if got := c.Playlists["Short"]; got != "duration < 20" {
+		t.Errorf("playlists.Short = %q, want it kept", got)
+	}
+
+	if err := main.ResetConfigValue("cache.up_next"); err != nil {
+		t.Fatalf("ResetConfigValue() error = %v", err)
+	}
+	c, _ = main.LoadConfig()
+	if got := c.Setting("cache.up_next").Source; got != "default" {
+		t.Errorf("Source after reset = %v, want default", got)
+	}
+}
+
+func TestSetConfigValue_InPlace(t *testing.T) {
+	const original = `# my podcasts
+[cache]
+retention = "60d"   # show notes and transcripts
+up_next = "30m"
+
+[playlists]
+Short = "duration < 20"
+`
+	tests := []struct {
+		name  string
+		key   string
+		value string
+		want  string
+	}{
+		{"replace", "cache.retention", "90d", `# my podcasts
+[cache]
+retention = "90d"   # show notes and transcripts
+up_next = "30m"
+
+[playlists]
+Short = "duration < 20"
+`},
+		{"insert into table", "cache.podcast_list", "2h", `# my podcasts
+[cache]
+retention = "60d"   # show notes and transcripts
+up_next = "30m"
+podcast_list = "2h"
+
+[playlists]
+Short = "duration < 20"
+`},
+		{"reset", "cache.up_next", "", `# my podcasts
+[cache]
+retention = "60d"   # show notes and transcripts
+
+[playlists]
+Short = "duration < 20"
+`},
+		{"new table", "episodes.page_size", "20", original + `
+[episodes]
+page_size = 20
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(file, []byte(original), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("PODCASTS_CONFIG", file)
+			var err error
+			if tt.value == "" {
+				err = main.ResetConfigValue(tt.key)
+			} else {
+				err = main.SetConfigValue(tt.key, tt.value)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := os.ReadFile(file)
+			if string(got) != tt.want {
+				t.Errorf("config file =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
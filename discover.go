@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const discoverLayoutURL = "https://static.pocketcasts.com/discover/json/layout.json"
+
+// DiscoverLayout is the index of Pocket Casts' public discover content
+type DiscoverLayout struct {
+	Layout            []*DiscoverSection         `json:"layout"`
+	Regions           map[string]*DiscoverRegion `json:"regions"`
+	RegionCodeToken   string                     `json:"region_code_token"`
+	DefaultRegionCode string                     `json:"default_region_code"`
+}
+
+type DiscoverRegion struct {
+	Name string `json:"name"`
+	Code string `json:"code"`
+}
+
+// DiscoverSection is a podcast list (featured, trending, popular, curated)
+// or the categories, available in `Regions`
+type DiscoverSection struct {
+	ID           string   `json:"id"`
+	Title        string   `json:"title"`
+	Type         string   `json:"type"`
+	SummaryStyle string   `json:"summary_style"`
+	Source       string   `json:"source"`
+	Regions      []string `json:"regions"`
+	Sponsored    bool     `json:"sponsored"`
+}
+
+type DiscoverCategory struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Source string `json:"source"`
+}
+
+type DiscoverList struct {
+	Title       string
+	Description string
+	Podcasts    []*Podcast
+}
+
+// Region is the configured region when the layout knows it, otherwise the
+// layout's default
+func (l *DiscoverLayout) Region() string {
+	if _, ok := l.Regions[config.Discover.Region]; ok {
+		return config.Discover.Region
+	}
+	return l.DefaultRegionCode
+}
+
+// Sections are the podcast lists and categories offered in `region`, with
+// the region filled into their sources
+func (l *DiscoverLayout) Sections(region string) []*DiscoverSection {
+	sections := make([]*DiscoverSection, 0)
+	for _, s := range l.Layout {
+		if s.Type != "podcast_list" && s.Type != "categories" || s.Sponsored || s.Source == "" {
+			continue
+		}
+		if !slices.Contains(s.Regions, region) {
+			continue
+		}
+		section := *s
+		section.Source = l.regionSource(s.Source, region)
+		sections = append(sections, &section)
+	}
+	return sections
+}
+
+func (l *DiscoverLayout) regionSource(source, region string) string {
+	if l.RegionCodeToken == "" {
+		return source
+	}
+	return strings.ReplaceAll(source, l.RegionCodeToken, region)
+}
+
+// readDiscover decodes `source` from the cache, or fetches it
+func readDiscover(source string, force bool, v any) error {
+	maxAge := time.Duration(config.Cache.Discover)
+	if force {
+		maxAge = 0
+	}
+	if data, err := readCache(getCachePath("discover", source), maxAge, "discover"); err == nil {
+		if err := json.Unmarshal(data, v); err == nil {
+			return nil
+		}
+	}
+	data, err := fetchDiscover(source)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// fetchDiscover downloads `source` into the cache. The content is public, so
+// no token is needed.
+func fetchDiscover(source string) ([]byte, error) {
+	_, _, data, err := fetch(source)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("invalid discover content: %s", source)
+	}
+	_ = writeCache(getCachePath("discover", source), data)
+	return data, nil
+}
+
+// refreshDiscover fetches again every cached discover source
+func refreshDiscover() error {
+	entries, err := os.ReadDir(getCachePath("discover"))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		source, err := url.PathUnescape(entry.Name())
+		if err != nil || !strings.HasPrefix(source, "https://") {
+			continue
+		}
+		if _, err := fetchDiscover(source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func GetDiscoverLayout(force bool) (*DiscoverLayout, error) {
+	var layout DiscoverLayout
+	if err := readDiscover(discoverLayoutURL, force, &layout); err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+func GetDiscoverCategories(source string, force bool) ([]*DiscoverCategory, error) {
+	categories := make([]*DiscoverCategory, 0)
+	if err := readDiscover(source, force, &categories); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func GetDiscoverList(source string, force bool) (*DiscoverList, error) {
+	var response struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Podcasts    []struct {
+			Name   string `json:"title"`
+			Author string `json:"author"`
+			Desc   string `json:"description"`
+			Link   string `json:"url"`
+			UUID   string `json:"uuid"`
+		} `json:"podcasts"`
+	}
+	if err := readDiscover(source, force, &response); err != nil {
+		return nil, err
+	}
+	list := &DiscoverList{Title: response.Title, Description: response.Description}
+	for _, podcast := range response.Podcasts {
+		list.Podcasts = append(list.Podcasts, &Podcast{
+			Name:   podcast.Name,
+			Author: podcast.Author,
+			Desc:   podcast.Desc,
+			Link:   podcast.Link,
+			UUID:   podcast.UUID,
+			Image:  podcastImageURL(podcast.UUID),
+		})
+	}
+	return list, nil
+}
+
+// discoveryProvider is a podcast directory to search in
+type discoveryProvider interface {
+	Name() string
+	SearchPodcasts(term string) ([]*Podcast, error)
+	SearchPerson(name string) ([]*Podcast, error)
+}
+
+type pocketCastsProvider struct{}
+
+func (pocketCastsProvider) Name() string {
+	return "Pocket Casts"
+}
+
+func (pocketCastsProvider) SearchPodcasts(term string) ([]*Podcast, error) {
+	return searchPodcasts(term)
+}
+
+// SearchPerson falls back to the search by term, which covers authors
+func (pocketCastsProvider) SearchPerson(name string) ([]*Podcast, error) {
+	return searchPodcasts(name)
+}
+
+// discoveryProviders starts with Pocket Casts, whose results can be
+// subscribed to without adding their feed
+func discoveryProviders() []discoveryProvider {
+	providers := []discoveryProvider{pocketCastsProvider{}}
+	if pi := podcastIndex(); pi != nil {
+		providers = append(providers, podcastIndexProvider{pi})
+	}
+	return providers
+}
+
+// DiscoverPodcasts searches every provider at once, for a person when the
+// term starts with `@`. A failing provider only leaves out its results.
+func DiscoverPodcasts(term string) ([]*Podcast, error) {
+	name, byPerson := strings.CutPrefix(term, "@")
+	providers := discoveryProviders()
+	results := make([][]*Podcast, len(providers))
+	errs := make([]error, len(providers))
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if byPerson {
+				results[i], errs[i] = p.SearchPerson(name)
+			} else {
+				results[i], errs[i] = p.SearchPodcasts(term)
+			}
+		}()
+	}
+	wg.Wait()
+
+	podcasts := MergePodcasts(results...)
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if len(podcasts) == 0 {
+			return nil, fmt.Errorf("%s: %v", providers[i].Name(), err)
+		}
+		fmt.Fprintf(os.Stderr, "Error searching %s: %v\n", providers[i].Name(), err)
+	}
+	return podcasts, nil
+}
+
+// MergePodcasts joins the lists in order, dropping podcasts listed before
+// under the same feed URL or iTunes ID, or the same name and author when
+// either lacks both. The podcast kept takes the feed and iTunes ID it lacks.
+func MergePodcasts(lists ...[]*Podcast) []*Podcast {
+	podcasts := make([]*Podcast, 0)
+	for _, list := range lists {
+	next:
+		for _, p := range list {
+			for _, _p := range podcasts {
+				if samePodcast(_p, p) {
+					if _p.URL == "" {
+						_p.URL = p.URL
+					}
+					if _p.ITunesID == 0 {
+						_p.ITunesID = p.ITunesID
+					}
+					continue next
+				}
+			}
+			podcasts = append(podcasts, p)
+		}
+	}
+	return podcasts
+}
+
+func samePodcast(a, b *Podcast) bool {
+	if a.UUID != "" && a.UUID == b.UUID {
+		return true
+	}
+	if a.URL != "" && feedKey(a.URL) == feedKey(b.URL) {
+		return true
+	}
+	if a.ITunesID != 0 && a.ITunesID == b.ITunesID {
+		return true
+	}
+	if (a.URL == "" && a.ITunesID == 0) || (b.URL == "" && b.ITunesID == 0) {
+		if !strings.EqualFold(strings.TrimSpace(a.Name), strings.TrimSpace(b.Name)) {
+			return false
+		}
+		return a.Author == "" || b.Author == "" || strings.EqualFold(strings.TrimSpace(a.Author), strings.TrimSpace(b.Author))
+	}
+	return false
+}
+
+// feedKey compares feed URLs without scheme, `www.` and trailing slash
+func feedKey(feed string) string {
+	u, err := url.Parse(strings.TrimSpace(feed))
+	if err != nil || u.Host == "" {
+		return strings.ToLower(feed)
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	key := host + strings.TrimSuffix(u.Path, "/")
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	return key
+}
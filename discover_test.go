@@ -0,0 +1,124 @@
+package main_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/twio142/alfred-podcasts"
+)
+
+const discoverLayoutJSON = `{
+	"layout": [
+		{"id": "featured", "title": "Featured", "type": "podcast_list", "summary_style": "carousel", "source": "https://lists.pocketcasts.com/featured.json", "regions": ["global", "us", "gb"]},
+		{"id": "trending", "title": "Trending", "type": "podcast_list", "source": "https://lists.pocketcasts.com/trending/[regionCode].json", "regions": ["us", "gb"]},
+		{"id": "categories", "title": "Categories", "type": "categories", "source": "https://static.pocketcasts.com/discover/json/categories_[regionCode].json", "regions": ["us", "gb"]},
+		{"id": "sponsored", "title": "Sponsored", "type": "podcast_list", "source": "https://lists.pocketcasts.com/sponsored.json", "regions": ["us"], "sponsored": true},
+		{"id": "network", "title": "Networks", "type": "network_list", "source": "https://lists.pocketcasts.com/networks.json", "regions": ["us"]},
+		{"id": "au_only", "title": "Popular in Australia", "type": "podcast_list", "source": "https://lists.pocketcasts.com/popular/au.json", "regions": ["au"]}
+	],
+	"regions": {"us": {"name": "United States", "code": "us"}, "gb": {"name": "United Kingdom", "code": "gb"}},
+	"region_code_token": "[regionCode]",
+	"default_region_code": "us"
+}`
+
+func TestDiscoverLayout_Sections(t *testing.T) {
+	var layout main.DiscoverLayout
+	if err := json.Unmarshal([]byte(discoverLayoutJSON), &layout); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string // description of this test case
+		region string
+		want   []string
+	}{
+		{name: "us", region: "us", want: []string{
+			"Featured https://lists.pocketcasts.com/featured.json",
+			"Trending https://lists.pocketcasts.com/trending/us.json",
+			"Categories https://static.pocketcasts.com/discover/json/categories_us.json",
+		}},
+		{name: "gb", region: "gb", want: []string{
+			"Featured https://lists.pocketcasts.com/featured.json",
+			"Trending https://lists.pocketcasts.com/trending/gb.json",
+			"Categories https://static.pocketcasts.com/discover/json/categories_gb.json",
+		}},
+		{name: "au", region: "au", want: []string{
+			"Popular in Australia https://lists.pocketcasts.com/popular/au.json",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, s := range layout.Sections(tt.region) {
+				got = append(got, s.Title+" "+s.Source)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Sections() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	// the layout itself keeps the token for other regions
+	if src := layout.Layout[1].Source; !strings.Contains(src, "[regionCode]") {
+		t.Errorf("Sections() changed the layout: %s", src)
+	}
+}
+
+func TestMergePodcasts(t *testing.T) {
+	tests := []struct {
+		name  string // description of this test case
+		lists [][]*main.Podcast
+		want  string
+	}{
+		{
+			name: "same feed",
+			lists: [][]*main.Podcast{
+				{{Name: "Show", UUID: "u1", URL: "https://example.com/feed/"}},
+				{{Name: "Show (Podcast Index)", URL: "http://www.example.com/feed"}},
+			},
+			want: "Show u1 https://example.com/feed/",
+		},
+		{
+			name: "same iTunes ID",
+			lists: [][]*main.Podcast{
+				{{Name: "Show", UUID: "u1", ITunesID: 42}},
+				{{Name: "Other title", URL: "https://example.com/feed", ITunesID: 42}},
+			},
+			want: "Show u1 https://example.com/feed",
+		},
+		{
+			name: "same name without feed",
+			lists: [][]*main.Podcast{
+				{{Name: "Indie Show", Author: "Jane", UUID: "u1"}},
+				{{Name: "indie show", Author: "Jane", URL: "https://example.com/feed", ITunesID: 7}},
+			},
+			want: "Indie Show u1 https://example.com/feed",
+		},
+		{
+			name: "same name by another author",
+			lists: [][]*main.Podcast{
+				{{Name: "The Show", Author: "Jane", UUID: "u1"}},
+				{{Name: "The Show", Author: "John", URL: "https://example.com/feed"}},
+			},
+			want: "The Show u1; The Show https://example.com/feed",
+		},
+		{
+			name: "different feeds",
+			lists: [][]*main.Podcast{
+				{{Name: "The Show", URL: "https://a.example/feed", ITunesID: 1}},
+				{{Name: "The Show", URL: "https://b.example/feed", ITunesID: 2}},
+			},
+			want: "The Show https://a.example/feed; The Show https://b.example/feed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, p := range main.MergePodcasts(tt.lists...) {
+				got = append(got, strings.Join(strings.Fields(p.Name+" "+p.UUID+" "+p.URL), " "))
+			}
+			if strings.Join(got, "; ") != tt.want {
+				t.Errorf("MergePodcasts() = %q, want %q", strings.Join(got, "; "), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Filter is a user-defined episode filter of the Pocket Casts account. The
+// server only stores the criteria; like the apps, the episodes are worked
+// out from the cached podcasts.
+type Filter struct {
+	UUID            string `json:"uuid"`
+	Title           string `json:"title"`
+	Deleted         bool   `json:"deleted"`
+	Manual          bool   `json:"manual"`
+	AllPodcasts     bool   `json:"allPodcasts"`
+	PodcastUUIDs    string `json:"podcastUuids"`
+	Unplayed        bool   `json:"unplayed"`
+	PartiallyPlayed bool   `json:"partiallyPlayed"`
+	Finished        bool   `json:"finished"`
+	Starred         bool   `json:"starred"`
+	Downloaded      bool   `json:"downloaded"`
+	NotDownloaded   bool   `json:"notDownloaded"`
+	FilterHours     int    `json:"filterHours"`
+	FilterDuration  bool   `json:"filterDuration"`
+	LongerThan      int    `json:"longerThan"`
+	ShorterThan     int    `json:"shorterThan"`
+	SortPosition    int    `json:"sortPosition"`
+	SortType        int    `json:"sortType"`
+}
+
+// sort types used by the Pocket Casts apps
+const (
+	filterSortNewest = iota
+	filterSortOldest
+	filterSortShortest
+	filterSortLongest
+)
+
+func GetFilters(force bool) ([]*Filter, error) {
+	filters := make([]*Filter, 0)
+	maxAge := time.Duration(config.Cache.Lists)
+	if force {
+		maxAge = 0
+	}
+	file := getCachePath("filters")
+	if data, err := readCache(file, maxAge, "filters"); err == nil {
+		if err := json.Unmarshal(data, &filters); err == nil {
+			return filters, nil
+		}
+	}
+	body := map[string]any{"v": 1}
+	var response PocketCastsFiltersResponse
+	if err := PocketCastsRequest("/user/playlist/list", &body, &response); err != nil {
+		return nil, err
+	}
+	for _, f := range response.Filters {
+		// manual playlists keep their own episode list, which is not supported yet
+		if !f.Deleted && !f.Manual {
+			filters = append(filters, f)
+		}
+	}
+	sort.SliceStable(filters, func(i, j int) bool {
+		return filters[i].SortPosition < filters[j].SortPosition
+	})
+	data, _ := json.Marshal(filters)
+	_ = writeCache(file, data)
+	return filters, nil
+}
+
+func GetFilter(uuid string) (*Filter, error) {
+	filters, err := GetFilters(false)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range filters {
+		if f.UUID == uuid {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("filter not found")
+}
+
+func (f *Filter) Match(e *Episode) bool {
+	if e.Archived {
+		return false
+	}
+	if !f.AllPodcasts && !slices.Contains(strings.Split(f.PodcastUUIDs, ","), e.PodcastUUID) {
+		return false
+	}
+	switch {
+	case e.IsPlayed():
+		if !f.Finished {
+			return false
+		}
+	case e.PlayedUpTo > 0:
+		if !f.PartiallyPlayed {
+			return false
+		}
+	default:
+		if !f.Unplayed {
+			return false
+		}
+	}
+	if f.Starred && !e.Starred {
+		return false
+	}
+	if f.Downloaded != f.NotDownloaded && e.downloaded() != f.Downloaded {
+		return false
+	}
+	if f.FilterHours > 0 && time.Since(e.Date) > time.Duration(f.FilterHours)*time.Hour {
+		return false
+	}
+	if f.FilterDuration {
+		if f.LongerThan > 0 && e.Duration < f.LongerThan*60 {
+			return false
+		}
+		if f.ShorterThan > 0 && e.Duration > f.ShorterThan*60 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *Filter) GetEpisodes(force bool) ([]*Episode, error) {
+	episodes := make([]*Episode, 0)
+	maxAge := time.Duration(config.Cache.Lists)
+	if force {
+		maxAge = 0
+	}
+	file := getCachePath("filter." + f.UUID)
+	if data, err := readCache(file, maxAge, "filter", f.UUID); err == nil {
+		if err := json.Unmarshal(data, &episodes); err == nil {
+			return episodes, nil
+		}
+	}
+	// the episodes are refreshed on their own schedule, forcing them here
+	// would fetch every podcast again
+	if err := GetAllPodcasts(false); err != nil {
+		return nil, err
+	}
+	for _, p := range podcastMap {
+		for _, e := range p.EpisodeMap {
+			if e.Podcast == "" {
+				e.Podcast = p.Name
+			}
+			if f.Match(e) {
+				// show notes would bloat the cache
+				_e := *e
+				_e.ShowNotes = ""
+				episodes = append(episodes, &_e)
+			}
+		}
+	}
+	sort.SliceStable(episodes, func(i, j int) bool {
+		a, b := episodes[i], episodes[j]
+		switch f.SortType {
+		case filterSortOldest:
+			return a.Date.Before(b.Date)
+		case filterSortShortest:
+			return a.Duration < b.Duration
+		case filterSortLongest:
+			return a.Duration > b.Duration
+		default:
+			return a.Date.After(b.Date)
+		}
+	})
+	data, _ := json.Marshal(episodes)
+	_ = writeCache(file, data)
+	return episodes, nil
+}
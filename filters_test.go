@@ -0,0 +1,62 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/twio142/alfred-podcasts"
+)
+
+func TestFilter_Match(t *testing.T) {
+	e := &main.Episode{
+		UUID:        "e1",
+		PodcastUUID: "p1",
+		Date:        time.Now().Add(-24 * time.Hour),
+		Duration:    25 * 60,
+		PlayedUpTo:  300,
+		Status:      2,
+	}
+	tests := []struct {
+		name   string // description of this test case
+		filter main.Filter
+		want   bool
+	}{
+		{
+			name:   "in progress",
+			filter: main.Filter{AllPodcasts: true, PartiallyPlayed: true},
+			want:   true,
+		},
+		{
+			name:   "unplayed only",
+			filter: main.Filter{AllPodcasts: true, Unplayed: true},
+			want:   false,
+		},
+		{
+			name:   "released in the last 12 hours",
+			filter: main.Filter{AllPodcasts: true, PartiallyPlayed: true, FilterHours: 12},
+			want:   false,
+		},
+		{
+			name:   "selected podcasts and duration",
+			filter: main.Filter{PodcastUUIDs: "p0,p1", PartiallyPlayed: true, FilterDuration: true, LongerThan: 20, ShorterThan: 40},
+			want:   true,
+		},
+		{
+			name:   "other podcasts",
+			filter: main.Filter{PodcastUUIDs: "p2", PartiallyPlayed: true},
+			want:   false,
+		},
+		{
+			name:   "starred",
+			filter: main.Filter{AllPodcasts: true, PartiallyPlayed: true, Starred: true},
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(e); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
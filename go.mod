@@ -7,3 +7,9 @@ require github.com/mozillazg/go-pinyin v0.21.0
 require golang.org/x/sync v0.22.0
 
 require github.com/joho/godotenv v1.5.1
+
+require golang.org/x/net v0.47.0
+
+require golang.org/x/image v0.25.0
+
+require github.com/BurntSushi/toml v1.6.0
@@ -7,13 +7,14 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 )
 
 func runCommand(command ...any) (any, error) {
 	if len(command) == 0 {
 		return "", fmt.Errorf("no command provided")
 	}
-	cmd := exec.Command("socat", "-", "/tmp/iina.sock")
+	cmd := exec.Command("socat", "-", config.Player.Socket)
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return "", err
@@ -67,6 +68,22 @@ func PlayEpisode(u string, position string) error {
 	}
 }
 
+// seekWhenLoaded waits for the player to open `u` before seeking, since
+// loading a playlist returns before the file is ready
+func seekWhenLoaded(u string, position int) error {
+	for range 20 {
+		if current, err := runCommand("get_property", "path"); err == nil {
+			if s, ok := current.(string); ok && stripTimestamp(s) == stripTimestamp(u) {
+				if _, err := getPosition(); err == nil {
+					return SeekTo(float64(position))
+				}
+			}
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	return fmt.Errorf("player did not load the episode")
+}
+
 func PlayPause(p ...bool) error {
 	if len(p) > 0 {
 		pause := "no"
@@ -81,6 +98,57 @@ func PlayPause(p ...bool) error {
 	}
 }
 
+func SeekTo(position float64) error {
+	_, err := runCommand("seek", position, "absolute")
+	return err
+}
+
+func getPosition() (float64, error) {
+	timePos, err := runCommand("get_property", "time-pos")
+	if err != nil {
+		return 0, err
+	}
+	pos, ok := timePos.(float64)
+	if !ok {
+		return 0, fmt.Errorf("invalid time position")
+	}
+	return pos, nil
+}
+
+// SetChapters replaces the chapter list of the current file, so that
+// the chapters show up in the player's OSD
+func SetChapters(chapters []*Chapter) error {
+	list := make([]map[string]any, len(chapters))
+	for i, c := range chapters {
+		list[i] = map[string]any{
+			"title": c.Title,
+			"time":  c.Start,
+		}
+	}
+	_, err := runCommand("set_property", "chapter-list", list)
+	return err
+}
+
+// currentEpisode maps the file being played back to the exported playlist
+func currentEpisode() (*Episode, error) {
+	path, err := runCommand("get_property", "path")
+	if err != nil {
+		return nil, err
+	}
+	filename, ok := path.(string)
+	if !ok {
+		return nil, fmt.Errorf("no file playing")
+	}
+	episodeMap, err := readPlaylist()
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := episodeMap[filename]; ok {
+		return e, nil
+	}
+	return nil, fmt.Errorf("episode not found")
+}
+
 func loadPlaylist(file string, flag ...string) error {
 	args := []any{"loadlist", file}
 	for _, f := range flag {
@@ -115,11 +183,22 @@ func readPlaylist() (map[string]*Episode, error) {
 			continue
 		}
 		if strings.HasPrefix(line, "# ") {
-			parts := strings.SplitN(line[2:], "\t", 2)
-			if len(parts) != 2 {
+			parts := strings.SplitN(line[2:], "\t", 3)
+			if len(parts) < 2 {
 				continue
 			}
-			currentEpisode = FindEpisode(map[string]string{"title": parts[1], "podcast": parts[0]})
+			// playlists written before the UUIDs were added only have titles
+			currentEpisode = nil
+			if len(parts) == 3 {
+				if podcastUUID, uuid, ok := strings.Cut(parts[2], "/"); ok {
+					if episodes := resolveEpisodes([][2]string{{podcastUUID, uuid}}); episodes[0].URL != "" {
+						currentEpisode = episodes[0]
+					}
+				}
+			}
+			if currentEpisode == nil {
+				currentEpisode = FindEpisode(map[string]string{"title": parts[1], "podcast": parts[0]})
+			}
 		} else if currentEpisode != nil {
 			episodeMap[line] = currentEpisode
 			currentEpisode = nil
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+type SearchIndex struct {
+	Postings map[string]map[string]int `json:"postings"`
+	Docs     map[string]*indexDoc      `json:"docs"`
+	Podcasts map[string]indexStamp     `json:"podcasts"`
+	dirty    bool
+}
+
+type indexDoc struct {
+	PodcastUUID string   `json:"podcast"`
+	Terms       []string `json:"terms"`
+}
+
+type indexStamp struct {
+	LastUpdated time.Time `json:"lastUpdated"`
+	Episodes    int       `json:"episodes"`
+}
+
+type SearchResult struct {
+	Episode *Episode
+	Score   int
+	Snippet string
+	Remote  bool // found by the Pocket Casts episode search only
+}
+
+const titleWeight = 3
+
+var (
+	tagRegexp   = regexp.MustCompile(`(?s)<[^>]*>`)
+	spaceRegexp = regexp.MustCompile(`\s+`)
+)
+
+func stripHTML(s string) string {
+	s = tagRegexp.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	return strings.TrimSpace(spaceRegexp.ReplaceAllString(s, " "))
+}
+
+// tokenize splits text into lowercase terms. Han characters are indexed one
+// by one, so both the characters and their pinyin (see `matchString`), per
+// syllable or as a word, match.
+func tokenize(text string) []string {
+	var terms []string
+	var b strings.Builder
+	flush := func() {
+		if b.Len() > 0 {
+			terms = append(terms, b.String())
+			b.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			terms = append(terms, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return terms
+}
+
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		Postings: make(map[string]map[string]int),
+		Docs:     make(map[string]*indexDoc),
+		Podcasts: make(map[string]indexStamp),
+	}
+}
+
+func LoadSearchIndex() *SearchIndex {
+	idx := NewSearchIndex()
+	file := getCachePath("search_index")
+	if data, err := readCache(file, time.Duration(math.MaxInt64)); err == nil {
+		if err := json.Unmarshal(data, idx); err != nil {
+			return NewSearchIndex()
+		}
+	}
+	return idx
+}
+
+func (idx *SearchIndex) Save() error {
+	if !idx.dirty {
+		return nil
+	}
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	file := getCachePath("search_index")
+	// background refreshes may save at the same time
+	tmp, err := os.CreateTemp(cacheDir, "search_index.*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	idx.dirty = false
+	return os.Rename(tmp.Name(), file)
+}
+
+func (idx *SearchIndex) removeDoc(uuid string) {
+	doc, ok := idx.Docs[uuid]
+	if !ok {
+		return
+	}
+	for _, t := range doc.Terms {
+		if posting, ok := idx.Postings[t]; ok {
+			delete(posting, uuid)
+			if len(posting) == 0 {
+				delete(idx.Postings, t)
+			}
+		}
+	}
+	delete(idx.Docs, uuid)
+}
+
+func (idx *SearchIndex) addDoc(e *Episode) {
+	weights := make(map[string]int)
+	for _, t := range tokenize(matchString(e.Title)) {
+		weights[t] += titleWeight
+	}
+	if e.ShowNotes != "" {
+		for _, t := range tokenize(matchString(stripHTML(e.ShowNotes))) {
+			weights[t]++
+		}
+	}
+	doc := &indexDoc{PodcastUUID: e.PodcastUUID, Terms: make([]string, 0, len(weights))}
+	for t, w := range weights {
+		if idx.Postings[t] == nil {
+			idx.Postings[t] = make(map[string]int)
+		}
+		idx.Postings[t][e.UUID] = w
+		doc.Terms = append(doc.Terms, t)
+	}
+	idx.Docs[e.UUID] = doc
+}
+
+func (idx *SearchIndex) Update(podcasts map[string]*Podcast) {
+	for uuid := range idx.Podcasts {
+		if _, ok := podcasts[uuid]; !ok {
+			idx.RemovePodcast(uuid)
+		}
+	}
+	for _, p := range podcasts {
+		idx.UpdatePodcast(p)
+	}
+}
+
+func (idx *SearchIndex) UpdatePodcast(p *Podcast) {
+	if p == nil || p.UUID == "" || len(p.EpisodeMap) == 0 {
+		return
+	}
+	stamp := indexStamp{LastUpdated: p.LastUpdated, Episodes: len(p.EpisodeMap)}
+	if s, ok := idx.Podcasts[p.UUID]; ok && s.Episodes == stamp.Episodes && s.LastUpdated.Equal(stamp.LastUpdated) {
+		return
+	}
+	idx.RemovePodcast(p.UUID)
+	for _, e := range p.EpisodeMap {
+		idx.addDoc(e)
+	}
+	idx.Podcasts[p.UUID] = stamp
+	idx.dirty = true
+}
+
+func (idx *SearchIndex) RemovePodcast(uuid string) {
+	for id, doc := range idx.Docs {
+		if doc.PodcastUUID == uuid {
+			idx.removeDoc(id)
+		}
+	}
+	if _, ok := idx.Podcasts[uuid]; ok {
+		delete(idx.Podcasts, uuid)
+		idx.dirty = true
+	}
+}
+
+// every query term must match, either exactly or as a prefix
+func (idx *SearchIndex) Query(query string) map[string]int {
+	var scores map[string]int
+	for _, q := range tokenize(query) {
+		matched := make(map[string]int)
+		for t, posting := range idx.Postings {
+			if !strings.HasPrefix(t, q) {
+				continue
+			}
+			for uuid, w := range posting {
+				if t == q {
+					w *= 2
+				}
+				matched[uuid] += w
+			}
+		}
+		if scores == nil {
+			scores = matched
+			continue
+		}
+		for uuid := range scores {
+			if w, ok := matched[uuid]; ok {
+				scores[uuid] += w
+			} else {
+				delete(scores, uuid)
+			}
+		}
+	}
+	return scores
+}
+
+func updateSearchIndex(podcasts ...*Podcast) error {
+	idx := LoadSearchIndex()
+	for _, p := range podcasts {
+		idx.UpdatePodcast(p)
+	}
+	return idx.Save()
+}
+
+func SearchEpisodes(query string) ([]*SearchResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("empty query")
+	}
+	if err := GetAllPodcasts(false); err != nil {
+		return nil, err
+	}
+	idx := LoadSearchIndex()
+	idx.Update(podcastMap)
+	if err := idx.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving search index: %v\n", err)
+	}
+
+	results := make([]*SearchResult, 0)
+	for uuid, score := range idx.Query(query) {
+		doc := idx.Docs[uuid]
+		p, ok := podcastMap[doc.PodcastUUID]
+		if !ok {
+			continue
+		}
+		e, ok := p.EpisodeMap[uuid]
+		if !ok {
+			continue
+		}
+		results = append(results, &SearchResult{
+			Episode: e,
+			Score:   score,
+			Snippet: snippet(e, query),
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Episode.Date.After(results[j].Episode.Date)
+	})
+	return results, nil
+}
+
+// SearchAllEpisodes searches the cached episodes and the Pocket Casts
+// directory at once. The remote search failing only leaves out its results.
+func SearchAllEpisodes(query string) ([]*SearchResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("empty query")
+	}
+	var local []*SearchResult
+	var remote []*Episode
+	var localErr, remoteErr error
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		local, localErr = SearchEpisodes(query)
+	}()
+	go func() {
+		defer wg.Done()
+		remote, remoteErr = SearchRemoteEpisodes(query)
+	}()
+	wg.Wait()
+
+	if localErr != nil && remoteErr != nil {
+		return nil, localErr
+	}
+	if remoteErr != nil {
+		fmt.Fprintf(os.Stderr, "Error searching Pocket Casts: %v\n", remoteErr)
+	}
+	// prefer the cached copies, which know the progress
+	for i, e := range remote {
+		if p, ok := podcastMap[e.PodcastUUID]; ok {
+			if _e, ok := p.EpisodeMap[e.UUID]; ok {
+				remote[i] = _e
+			}
+		}
+	}
+	return MergeSearchResults(local, remote), nil
+}
+
+// MergeSearchResults appends the remote episodes missing from the ranked
+// local results, in their own order
+func MergeSearchResults(local []*SearchResult, remote []*Episode) []*SearchResult {
+	results := make([]*SearchResult, 0, len(local)+len(remote))
+	seen := make(map[string]bool)
+	for _, r := range local {
+		results = append(results, r)
+		seen[r.Episode.UUID] = true
+	}
+	for _, e := range remote {
+		if seen[e.UUID] {
+			continue
+		}
+		results = append(results, &SearchResult{Episode: e, Remote: true})
+		seen[e.UUID] = true
+	}
+	return results
+}
+
+// snippet returns the text around the first query term found in the show
+// notes, or an empty string when only the title matched.
+func snippet(e *Episode, query string) string {
+	const radius = 40
+	text := []rune(stripHTML(e.ShowNotes))
+	lower := []rune(strings.ToLower(string(text)))
+	if len(lower) != len(text) {
+		text = lower
+	}
+	for _, q := range tokenize(query) {
+		pos := strings.Index(string(lower), q)
+		if pos < 0 {
+			continue
+		}
+		start := len([]rune(string(lower)[:pos]))
+		from := max(start-radius, 0)
+		to := min(start+len([]rune(q))+radius, len(text))
+		s := strings.TrimSpace(string(text[from:to]))
+		if from > 0 {
+			s = "…" + s
+		}
+		if to < len(text) {
+			s += "…"
+		}
+		return s
+	}
+	return ""
+}
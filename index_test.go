@@ -0,0 +1,114 @@
+package main_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/twio142/alfred-podcasts"
+)
+
+func TestSearchEpisodes(t *testing.T) {
+	tests := []struct {
+		name    string // description of this test case
+		query   string
+		wantErr bool
+	}{
+		{
+			name:    "search episodes",
+			query:   "interview",
+			wantErr: false,
+		},
+		{
+			name:    "empty query",
+			query:   " ",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := main.SearchEpisodes(tt.query)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("SearchEpisodes() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("SearchEpisodes() succeeded unexpectedly")
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i].Score > got[i-1].Score {
+					t.Errorf("SearchEpisodes() results not ranked: %d > %d", got[i].Score, got[i-1].Score)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchIndex_Query(t *testing.T) {
+	idx := main.NewSearchIndex()
+	idx.UpdatePodcast(&main.Podcast{
+		UUID: "p",
+		EpisodeMap: map[string]*main.Episode{
+			"zh": {UUID: "zh", PodcastUUID: "p", Title: "播客 访谈"},
+			"en": {UUID: "en", PodcastUUID: "p", Title: "An Interview", ShowNotes: "<p>About <b>climate</b></p>"},
+		},
+	})
+	tests := []struct {
+		name  string // description of this test case
+		query string
+		want  string
+	}{
+		{name: "word", query: "interview", want: "en"},
+		{name: "prefix", query: "clim", want: "en"},
+		{name: "han character", query: "播", want: "zh"},
+		{name: "pinyin syllable", query: "ke", want: "zh"},
+		{name: "pinyin word", query: "boke", want: "zh"},
+		{name: "pinyin word prefix", query: "fangt", want: "zh"},
+		{name: "pinyin across words", query: "kefang", want: ""},
+		{name: "all terms must match", query: "interview boke", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for uuid := range idx.Query(tt.query) {
+				got = append(got, uuid)
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("Query() = %q, want %q", strings.Join(got, " "), tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeSearchResults(t *testing.T) {
+	local := []*main.SearchResult{
+		{Episode: &main.Episode{UUID: "a"}, Score: 9},
+		{Episode: &main.Episode{UUID: "b"}, Score: 3},
+	}
+	tests := []struct {
+		name   string // description of this test case
+		remote []*main.Episode
+		want   string
+	}{
+		{name: "no remote results", want: "a b"},
+		{name: "remote appended", remote: []*main.Episode{{UUID: "c"}, {UUID: "d"}}, want: "a b c* d*"},
+		{name: "local matches kept once", remote: []*main.Episode{{UUID: "b"}, {UUID: "c"}, {UUID: "a"}}, want: "a b c*"},
+		{name: "remote duplicates", remote: []*main.Episode{{UUID: "c"}, {UUID: "c"}}, want: "a b c*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, r := range main.MergeSearchResults(local, tt.remote) {
+				if r.Remote {
+					got = append(got, r.Episode.UUID+"*")
+				} else {
+					got = append(got, r.Episode.UUID)
+				}
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("MergeSearchResults() = %q, want %q", strings.Join(got, " "), tt.want)
+			}
+		})
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"net/url"
 	"os"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -90,12 +92,108 @@ func ListUpNext() {
 			item.Mods.Cmd = nil
 		}
 		item.Mods.Shift.SetVar("prevTrigger", "queue")
+		// ⇧⌘ edit queue
+		cmdShift := &Mod{Subtitle: "Edit queue…"}
+		cmdShift.SetVar("trigger", "queue_edit")
+		cmdShift.SetVar("uuid", e.UUID)
+		cmdShift.SetVar("podcastUuid", e.PodcastUUID)
+		item.Mods.CmdShift = cmdShift
 		workflow.AddItem(item)
 	}
 	upNextSummary(episodes)
 }
 
-func (p *Podcast) ListEpisodes(goBackTo string) {
+func ListQueueActions(uuid string) {
+	episodes, err := GetUpNext(false)
+	if err != nil {
+		workflow.WarnEmpty(err.Error())
+		return
+	}
+	i := -1
+	for j, e := range episodes {
+		if e.UUID == uuid {
+			i = j
+			break
+		}
+	}
+	type queueAction struct {
+		title  string
+		action string
+		show   bool
+	}
+	actions := []queueAction{
+		{"Move to Top", "moveToTop", i > 0},
+		{"Move Up", "moveUp", i > 0},
+		{"Move Down", "moveDown", i >= 0 && i < len(episodes)-1},
+		{"Move to Bottom", "moveToBottom", i >= 0 && i < len(episodes)-1},
+		{"Remove from Queue", "removeFromQueue", i >= 0},
+		{"Remove Played Episodes", "removePlayed", true},
+		{"Remove Duplicates", "dedupeQueue", true},
+		{"Shuffle by Podcast", "shuffleQueue", len(episodes) > 2},
+		{"Clear Queue", "clearQueue", len(episodes) > 0},
+	}
+	for _, a := range actions {
+		if !a.show {
+			continue
+		}
+		item := Item{Title: a.title}
+		if i >= 0 {
+			item.Subtitle = episodes[i].Title
+		}
+		item.SetVar("action", a.action)
+		workflow.AddItem(&item)
+	}
+	fill := Item{Title: "Fill Queue…", Subtitle: "Add new episodes up to a target listening time"}
+	fill.SetVar("trigger", "fill_queue")
+	fill.SetVar("withQuery", "1")
+	workflow.AddItem(&fill)
+	item := Item{
+		Title: "Go Back",
+		Icon:  &Icon{Path: "icons/back.png"},
+	}
+	item.SetVar("trigger", "queue")
+	workflow.AddItem(&item)
+}
+
+func ListSearchResults(query string) {
+	if query == "" {
+		workflow.WarnEmpty("Search Episodes")
+		return
+	}
+	results, err := SearchAllEpisodes(query)
+	if err != nil {
+		workflow.WarnEmpty(err.Error())
+		return
+	}
+	if len(results) == 0 {
+		workflow.WarnEmpty("No Episodes Found")
+		return
+	}
+	_, _ = GetUpNext(false)
+	for i, r := range results {
+		if i == 50 {
+			break
+		}
+		var item *Item
+		if r.Remote {
+			// fetching the podcast of every result would be too slow; the
+			// episode is looked up when it is played
+			item = r.Episode.format(false)
+		} else {
+			item = r.Episode.Format(false)
+		}
+		if r.Snippet != "" {
+			item.Subtitle = r.Snippet
+		} else {
+			item.Subtitle = fmt.Sprintf("􀪔 %s  ·  %s", r.Episode.Podcast, item.Subtitle)
+		}
+		item.Match = ""
+		item.Mods.Shift.SetVar("prevTrigger", "episode_search")
+		workflow.AddItem(item)
+	}
+}
+
+func (p *Podcast) ListEpisodes(goBackTo string, query string, offset int, showArchived bool) {
 	if p == nil {
 		workflow.WarnEmpty("Podcast Not Found")
 		return
@@ -109,21 +207,27 @@ func (p *Podcast) ListEpisodes(goBackTo string) {
 		workflow.AddItem(&item)
 		return
 	}
-	episodes := make([]*Episode, 0, len(p.EpisodeMap))
-	for _, e := range p.EpisodeMap {
-		episodes = append(episodes, e)
+	mode, term := parseEpisodeQuery(query)
+	episodes := p.FilterEpisodes(term, mode)
+	archived := 0
+	if !showArchived {
+		unarchived := make([]*Episode, 0, len(episodes))
+		for _, e := range episodes {
+			if e.Archived {
+				archived++
+			} else {
+				unarchived = append(unarchived, e)
+			}
+		}
+		episodes = unarchived
+	}
+	if offset < 0 || offset >= len(episodes) {
+		offset = 0
 	}
-	sort.Slice(episodes, func(i, j int) bool {
-		return episodes[i].Date.After(episodes[j].Date)
-	})
 	_, _ = GetUpNext(false)
-	for i, e := range episodes {
-		if i == 30 {
-			break
-		}
+	for _, e := range episodes[offset:min(offset+config.Episodes.PageSize, len(episodes))] {
 		item := e.Format(false)
 		item.Subtitle = fmt.Sprintf("􀪔 %s  ·  %s", e.Podcast, item.Subtitle)
-		item.Match = matchString(e.Title)
 		item.AutoComplete = ""
 		// ⇧⌘ refresh podcast
 		cmdShift := &Mod{Subtitle: "Refresh podcast", Icon: &Icon{Path: "icons/refresh.png"}}
@@ -135,19 +239,64 @@ func (p *Podcast) ListEpisodes(goBackTo string) {
 		item.Mods.Fn = nil
 		workflow.AddItem(item)
 	}
+	if len(episodes) == 0 {
+		workflow.WarnEmpty("No Episodes Found")
+	}
+	if next := offset + config.Episodes.PageSize; next < len(episodes) {
+		item := Item{
+			Title:        fmt.Sprintf("Load %d More", min(config.Episodes.PageSize, len(episodes)-next)),
+			Subtitle:     fmt.Sprintf("Showing %d–%d of %d  ·  sorted by %s", offset+1, next, len(episodes), mode),
+			AutoComplete: query,
+		}
+		item.SetVar("trigger", "episodes")
+		item.SetVar("withQuery", "1")
+		item.SetVar("episodeQuery", query)
+		item.SetVar("podcastUuid", p.UUID)
+		item.SetVar("prevTrigger", goBackTo)
+		item.SetVar("offset", fmt.Sprintf("%d", next))
+		if showArchived {
+			item.SetVar("showArchived", "1")
+		}
+		workflow.AddItem(&item)
+	}
+	if archived > 0 || showArchived {
+		item := Item{
+			Title:        fmt.Sprintf("Show %d Archived Episodes", archived),
+			AutoComplete: query,
+		}
+		item.SetVar("showArchived", "1")
+		if showArchived {
+			item.Title = "Hide Archived Episodes"
+			item.SetVar("showArchived", "")
+		}
+		item.SetVar("trigger", "episodes")
+		item.SetVar("withQuery", "1")
+		item.SetVar("episodeQuery", query)
+		item.SetVar("podcastUuid", p.UUID)
+		item.SetVar("prevTrigger", goBackTo)
+		item.SetVar("offset", "")
+		workflow.AddItem(&item)
+	}
 	item := Item{
 		Title: "Go Back",
 		Icon:  &Icon{Path: "icons/back.png"},
 	}
 	item.SetVar("trigger", goBackTo)
+	if goBackTo == "episode_search" {
+		item.SetVar("withQuery", "1")
+	}
+	item.SetVar("offset", "")
+	item.SetVar("showArchived", "")
 	workflow.AddItem(&item)
 	workflow.SetVar("prevTrigger", "")
+	workflow.SetVar("offset", "")
+	workflow.SetVar("showArchived", "")
 }
 
 func (p *Podcast) Format(search bool) *Item {
 	icon := &Icon{Path: getCachePath("artworks", p.UUID)}
 	_, err := os.Stat(icon.Path)
-	if err != nil {
+	if err != nil || p.UUID == "" {
 		icon = nil
 	}
 	item := Item{
@@ -170,16 +319,20 @@ func (p *Podcast) Format(search bool) *Item {
 			AltShift  *Mod `json:"alt+shift,omitempty"`
 			CtrlShift *Mod `json:"ctrl+shift,omitempty"`
 			CmdShift  *Mod `json:"cmd+shift,omitempty"`
+			CmdAlt    *Mod `json:"cmd+alt,omitempty"`
 		}{},
 	}
 
-	if p.Link == "" {
+	if p.Link == "" && p.UUID != "" {
 		item.QuickLookURL = "https://pocketcasts.com/podcasts/" + p.UUID
 	}
 
 	// ↵ list episodes
 	item.SetVar("trigger", "episodes")
+	item.SetVar("withQuery", "1")
 	item.SetVar("podcastUuid", p.UUID)
+	// found outside Pocket Casts, listed and subscribed to by its feed
+	item.SetVar("feedUrl", p.URL)
 
 	if search {
 		item.Arg = " "
@@ -196,6 +349,7 @@ func (p *Podcast) Format(search bool) *Item {
 		}
 		cmd.SetVar("podcastUuid", p.UUID)
 		cmd.SetVar("podcast", p.Name)
+		cmd.SetVar("feedUrl", p.URL)
 		item.Mods.Cmd = cmd
 	} else {
 		// ⌘ refresh podcast
@@ -219,30 +373,70 @@ func (p *Podcast) Format(search bool) *Item {
 }
 
 func (e *Episode) Format(upNext bool) *Item {
-	icon := &Icon{Path: getCachePath("artworks", e.PodcastUUID)}
-	if _, err := os.Stat(icon.Path); err != nil {
-		icon = nil
-	}
+	e.fillDetails()
+	return e.format(upNext)
+}
+
+// fillDetails takes what the list left out, like the show notes, from the
+// podcast's episodes
+func (e *Episode) fillDetails() {
 	if e.Duration == 0 || e.ShowNotes == "" {
 		p := &Podcast{UUID: e.PodcastUUID}
 		if err := p.GetEpisodes(false); err == nil {
 			if _e, ok := p.EpisodeMap[e.UUID]; ok {
 				e.Duration = _e.Duration
 				e.ShowNotes = _e.ShowNotes
+				if e.URL == "" {
+					e.URL = _e.URL
+				}
 				e.Date = _e.Date
 				e.Image = _e.Image
+				if e.Status == 0 {
+					e.Status = _e.Status
+					e.Archived = _e.Archived
+					e.Starred = _e.Starred
+				}
+				if e.PlayedUpTo == 0 {
+					e.PlayedUpTo = _e.PlayedUpTo
+				}
 			}
 		}
 	}
+}
+
+func (e *Episode) format(upNext bool) *Item {
+	icon := &Icon{Path: e.artworkPath()}
+	if _, err := os.Stat(icon.Path); err != nil {
+		if e.HasOwnArtwork() && needsArtwork(icon.Path) {
+			missingArtworks = append(missingArtworks, e.PodcastUUID+"/"+e.UUID)
+		}
+		icon = &Icon{Path: getCachePath("artworks", e.PodcastUUID)}
+		if _, err := os.Stat(icon.Path); err != nil {
+			icon = nil
+		}
+	}
+	subtitle := fmt.Sprintf("􀉉 %s  ·  􀖈 %s", e.Date.Format("Mon, 2006-01-02"), formatDuration(e.Duration))
+	if e.IsPlayed() {
+		subtitle += "  ·  Played"
+	} else if e.PlayedUpTo > 0 && e.Duration > 0 {
+		subtitle += fmt.Sprintf("  ·  %s %s left", progressBar(e.PlayedUpTo, e.Duration), formatDuration(e.Duration-e.PlayedUpTo))
+	}
+	if e.Archived {
+		subtitle += "  ·  Archived"
+	}
 	item := Item{
 		Title:        e.Title,
-		Subtitle:     fmt.Sprintf("􀉉 %s  ·  􀖈 %s", e.Date.Format("Mon, 2006-01-02"), formatDuration(e.Duration)),
+		Subtitle:     subtitle,
 		Arg:          e.URL,
 		UID:          e.UUID,
 		QuickLookURL: e.CacheShownotes(),
 		Icon:         icon,
 		Match:        matchString(e.Title, e.Podcast),
 		AutoComplete: e.Podcast,
+		Text: struct {
+			Copy      string `json:"copy,omitempty"`
+			LargeType string `json:"largetype,omitempty"`
+		}{Copy: e.ShareURL(0)},
 		Mods: struct {
 			Cmd       *Mod `json:"cmd,omitempty"`
 			Alt       *Mod `json:"alt,omitempty"`
@@ -252,8 +446,12 @@ func (e *Episode) Format(upNext bool) *Item {
 			AltShift  *Mod `json:"alt+shift,omitempty"`
 			CtrlShift *Mod `json:"ctrl+shift,omitempty"`
 			CmdShift  *Mod `json:"cmd+shift,omitempty"`
+			CmdAlt    *Mod `json:"cmd+alt,omitempty"`
 		}{},
 	}
+	if e.IsPlayed() {
+		item.Title = "􀁣 " + item.Title
+	}
 	action := "action"
 	if !upNext {
 		if _, ok := upNextMap[e.UUID]; ok {
@@ -283,26 +481,611 @@ func (e *Episode) Format(upNext bool) *Item {
 	// ⇧ list episodes of this podcast
 	shift := &Mod{Subtitle: "􀪔 " + e.Podcast}
 	shift.SetVar("trigger", "episodes")
+	shift.SetVar("withQuery", "1")
 	shift.SetVar("podcastUuid", e.PodcastUUID)
 	item.Mods.Shift = shift
 
-	// ⌃ mark episode as played
+	// ⌃ mark episode as played / unplayed
 	ctrl := &Mod{Subtitle: "Mark as played", Icon: &Icon{Path: "icons/check.png"}}
 	ctrl.SetVar(action, "markAsPlayed")
+	if e.IsPlayed() {
+		ctrl.Subtitle = "Mark as unplayed"
+		ctrl.SetVar(action, "markAsUnplayed")
+	}
 	ctrl.SetVar("uuid", e.UUID)
 	ctrl.SetVar("podcastUuid", e.PodcastUUID)
 	item.Mods.Ctrl = ctrl
 
-	//  archive episode
+	//  archive / unarchive episode
 	fn := &Mod{Subtitle: "Archive", Icon: &Icon{Path: "icons/archive.png"}}
 	fn.SetVar(action, "archive")
+	if e.Archived {
+		fn.Subtitle = "Unarchive"
+		fn.SetVar(action, "unarchive")
+	}
 	fn.SetVar("uuid", e.UUID)
 	fn.SetVar("podcastUuid", e.PodcastUUID)
 	item.Mods.Fn = fn
 
+	// ⇧⌥ reset playback position
+	if e.PlayedUpTo > 0 {
+		altShift := &Mod{Subtitle: "Reset progress", Icon: &Icon{Path: "icons/refresh.png"}}
+		altShift.SetVar(action, "resetProgress")
+		altShift.SetVar("uuid", e.UUID)
+		altShift.SetVar("podcastUuid", e.PodcastUUID)
+		item.Mods.AltShift = altShift
+	}
+
+	// ⇧⌃ set playback position
+	ctrlShift := &Mod{Subtitle: "Set position…"}
+	ctrlShift.SetVar("trigger", "position")
+	ctrlShift.SetVar("withQuery", "1")
+	ctrlShift.SetVar("uuid", e.UUID)
+	ctrlShift.SetVar("podcastUuid", e.PodcastUUID)
+	item.Mods.CtrlShift = ctrlShift
+
+	// ⌘⌥ share episode
+	cmdAlt := &Mod{Subtitle: "Share…"}
+	cmdAlt.SetVar("trigger", "share")
+	cmdAlt.SetVar("uuid", e.UUID)
+	cmdAlt.SetVar("podcastUuid", e.PodcastUUID)
+	item.Mods.CmdAlt = cmdAlt
+
 	return &item
 }
 
+func ListChapters(e *Episode) {
+	if e == nil {
+		workflow.WarnEmpty("No Episode Playing")
+		return
+	}
+	chapters, err := e.GetChapters(false)
+	if err != nil {
+		workflow.WarnEmpty(err.Error())
+		return
+	}
+	if len(chapters) == 0 {
+		workflow.WarnEmpty("No Chapters Found")
+		return
+	}
+	playing := false
+	current := -1
+	if c, err := currentEpisode(); err == nil && c.UUID == e.UUID {
+		playing = true
+		_ = SetChapters(chapters)
+		if pos, err := getPosition(); err == nil {
+			current = chapterAt(chapters, pos)
+		}
+	}
+	for i, c := range chapters {
+		valid := playing
+		item := Item{
+			Title:        c.Title,
+			Subtitle:     fmt.Sprintf("􀖈 %s  ·  %s", formatTimestamp(int(c.Start)), e.Title),
+			Valid:        &valid,
+			QuickLookURL: c.URL,
+			Match:        matchString(c.Title),
+		}
+		if i == current {
+			item.Title = "􀊄 " + item.Title
+		}
+		item.Text.Copy = fmt.Sprintf("%s %s", formatTimestamp(int(c.Start)), c.Title)
+		if playing {
+			item.SetVar("action", "seek")
+			item.SetVar("position", fmt.Sprintf("%d", int(c.Start)))
+		}
+		workflow.AddItem(&item)
+	}
+}
+
+func ListTranscriptSearch(e *Episode, query string) {
+	if e == nil {
+		workflow.WarnEmpty("No Episode Playing")
+		return
+	}
+	segments, err := SearchTranscript(e, query)
+	if err != nil {
+		workflow.WarnEmpty(err.Error())
+		return
+	}
+	if len(segments) == 0 {
+		workflow.WarnEmpty("No Match Found")
+		return
+	}
+	playing := false
+	if c, err := currentEpisode(); err == nil && c.UUID == e.UUID {
+		playing = true
+	}
+	transcript := e.CacheTranscript()
+	for _, seg := range segments {
+		valid := playing
+		subtitle := "􀖈 " + formatTimestamp(int(seg.Start))
+		if seg.Speaker != "" {
+			subtitle += "  ·  " + seg.Speaker
+		}
+		item := Item{
+			Title:        seg.Text,
+			Subtitle:     subtitle,
+			Valid:        &valid,
+			QuickLookURL: transcript,
+		}
+		item.Text.Copy = seg.Text
+		item.Text.LargeType = seg.Text
+		if playing {
+			item.SetVar("action", "seek")
+			item.SetVar("position", fmt.Sprintf("%d", int(seg.Start)))
+		}
+		workflow.AddItem(&item)
+	}
+}
+
+func ListLinks(e *Episode) {
+	if e == nil {
+		workflow.WarnEmpty("No Episode Selected")
+		return
+	}
+	if e.ShowNotes == "" {
+		p := &Podcast{UUID: e.PodcastUUID}
+		if err := p.GetEpisodes(false); err == nil {
+			if _e, ok := p.EpisodeMap[e.UUID]; ok {
+				e.ShowNotes = _e.ShowNotes
+			}
+		}
+	}
+	episode := e.PodcastUUID + "/" + e.UUID
+	links, err := ExtractLinks(e.ShowNotes, episode)
+	if err != nil {
+		workflow.WarnEmpty(err.Error())
+		return
+	}
+	if len(links) == 0 {
+		workflow.WarnEmpty("No Links Found")
+		return
+	}
+	kinds := map[string]string{
+		"web":       "􀎬",
+		"book":      "􀉚",
+		"social":    "􀉭",
+		"podcast":   "􀑪",
+		"timestamp": "􀐫",
+	}
+	all := LinksMarkdown(links)
+	for _, l := range links {
+		subtitle := kinds[l.Kind]
+		if u, err := url.Parse(l.URL); err == nil && l.Kind != "timestamp" {
+			subtitle += " " + strings.TrimPrefix(u.Hostname(), "www.")
+		}
+		if l.Detail != "" && l.Kind != "timestamp" {
+			subtitle += "  ·  " + l.Detail
+		}
+		item := Item{
+			Title:    l.Text,
+			Subtitle: subtitle,
+			Arg:      l.URL,
+			Match:    matchString(l.Text, l.URL),
+		}
+		item.Text.Copy = l.URL
+		if l.Kind == "timestamp" {
+			item.Subtitle = fmt.Sprintf("%s Seek to %s", subtitle, formatTimestamp(atoi(l.Detail)))
+			item.SetVar("action", "seek")
+			item.SetVar("position", seekArg(episode, atoi(l.Detail)))
+		} else {
+			// ↵ open link
+			item.QuickLookURL = l.URL
+			item.SetVar("action", "open")
+			item.SetVar("url", l.URL)
+
+			// ⌘ copy link
+			cmd := &Mod{Subtitle: "Copy link"}
+			cmd.SetVar("action", "copy")
+			cmd.SetVar("text", l.URL)
+			item.Mods.Cmd = cmd
+		}
+
+		// ⌥ copy all links as a Markdown list
+		alt := &Mod{Subtitle: "Copy all as Markdown list"}
+		alt.SetVar("action", "copy")
+		alt.SetVar("text", all)
+		item.Mods.Alt = alt
+		workflow.AddItem(&item)
+	}
+}
+
+func ListQueueFill(query string) {
+	valid := false
+	item := Item{
+		Title:    "Fill Queue",
+		Subtitle: "Type a target listening time, e.g. 3h or 90 (minutes)",
+		Valid:    &valid,
+	}
+	if query == "" {
+		workflow.AddItem(&item)
+		return
+	}
+	target, err := parseRuleDuration(strings.TrimSpace(query))
+	if err != nil {
+		item.Subtitle = err.Error()
+		workflow.AddItem(&item)
+		return
+	}
+	plan, err := PlanQueueFill(target)
+	if err != nil {
+		workflow.WarnEmpty(err.Error())
+		return
+	}
+	if len(plan.Episodes) == 0 {
+		item.Title = "Nothing to Add"
+		item.Subtitle = fmt.Sprintf("Queue: %s  ·  target: %s", formatDuration(plan.Queued), formatDuration(target))
+		workflow.AddItem(&item)
+		return
+	}
+	valid = true
+	item.Title = fmt.Sprintf("Add %d Episodes", len(plan.Episodes))
+	item.Subtitle = fmt.Sprintf("Queue: %s → %s  ·  target: %s", formatDuration(plan.Queued), formatDuration(plan.Total()), formatDuration(target))
+	item.SetVar("action", "fill_queue")
+	item.SetVar("target", fmt.Sprintf("%d", target))
+	workflow.AddItem(&item)
+	for _, e := range plan.Episodes {
+		item := e.Format(false)
+		item.Subtitle = fmt.Sprintf("􀪔 %s  ·  %s", e.Podcast, item.Subtitle)
+		item.Mods.Shift.SetVar("prevTrigger", "queue")
+		workflow.AddItem(item)
+	}
+}
+
+func ListStats() {
+	s, err := GetStats(false)
+	if err != nil {
+		workflow.WarnEmpty(err.Error())
+		return
+	}
+	valid := false
+	listened := Item{
+		Title: "Listened for " + formatHours(s.Listened),
+		Valid: &valid,
+	}
+	if !s.StartedAt.IsZero() {
+		listened.Subtitle = "Since " + s.StartedAt.Format("January 2, 2006")
+	}
+	workflow.AddItem(&listened)
+	saved := Item{
+		Title: "Saved " + formatHours(s.Saved()),
+		Subtitle: fmt.Sprintf("Speed: %s  ·  Silence: %s  ·  Skipping: %s  ·  Intros: %s",
+			formatHours(s.VariableSpeed), formatHours(s.SilenceRemoval), formatHours(s.Skipping), formatHours(s.IntroSkipping)),
+		Valid: &valid,
+	}
+	workflow.AddItem(&saved)
+	if s.QueueLength > 0 {
+		item := Item{
+			Title:    fmt.Sprintf("%d Episodes in Up Next", s.QueueLength),
+			Subtitle: fmt.Sprintf("%s old on average", formatHours(int(s.QueueAge.Seconds()))),
+		}
+		item.SetVar("trigger", "queue")
+		workflow.AddItem(&item)
+	}
+	finished, most := 0, 0
+	for _, w := range s.FinishedPerWeek {
+		finished += w.Count
+		most = max(most, w.Count)
+	}
+	weeks := Item{
+		Title:    fmt.Sprintf("%d Episodes Finished in %d Weeks", finished, len(s.FinishedPerWeek)),
+		Subtitle: "Marked as played in this workflow",
+		Valid:    &valid,
+	}
+	if most > 0 {
+		spark := []rune("▁▂▃▄▅▆▇█")
+		bars := make([]rune, 0, len(s.FinishedPerWeek))
+		for _, w := range s.FinishedPerWeek {
+			bars = append(bars, spark[w.Count*(len(spark)-1)/most])
+		}
+		weeks.Subtitle = string(bars) + "  ·  " + weeks.Subtitle
+	}
+	workflow.AddItem(&weeks)
+	for i, pt := range s.PodcastTime {
+		if i == 10 {
+			break
+		}
+		item := Item{
+			Title:    pt.Name,
+			Subtitle: fmt.Sprintf("%s  %s", progressBar(pt.Seconds, s.PodcastTime[0].Seconds), formatHours(pt.Seconds)),
+			Icon:     &Icon{Path: getCachePath("artworks", pt.UUID)},
+		}
+		item.SetVar("trigger", "episodes")
+		item.SetVar("withQuery", "1")
+		item.SetVar("podcastUuid", pt.UUID)
+		item.SetVar("prevTrigger", "stats")
+		workflow.AddItem(&item)
+	}
+	report := Item{
+		Title:    "Export Report",
+		Subtitle: "↵ HTML  ·  ⌘ Markdown",
+	}
+	report.SetVar("action", "exportStats")
+	report.SetVar("format", "html")
+	cmd := &Mod{Subtitle: "Export Markdown report"}
+	cmd.SetVar("action", "exportStats")
+	cmd.SetVar("format", "md")
+	report.Mods.Cmd = cmd
+	// ⇧⌘ refresh stats
+	cmdShift := &Mod{Subtitle: "Refresh stats", Icon: &Icon{Path: "icons/refresh.png"}}
+	cmdShift.SetVar("refresh", "stats")
+	report.Mods.CmdShift = cmdShift
+	workflow.AddItem(&report)
+}
+
+// configWarnings puts the config errors on top of the results
+func configWarnings(errs []error) {
+	for i := len(errs) - 1; i >= 0; i-- {
+		item := Item{
+			Title:    "Invalid Config: " + errs[i].Error(),
+			Subtitle: "Open settings",
+			Icon:     &Icon{Path: os.Getenv("alfred_preferences") + "/resources/AlertCautionIcon.icns"},
+		}
+		item.SetVar("trigger", "settings")
+		item.SetVar("withQuery", "1")
+		workflow.UnshiftItem(&item)
+	}
+}
+
+// ListSettings shows the settings, and edits one when the query is
+// `key value`
+func ListSettings(query string, errs []error) {
+	key, value, editing := strings.Cut(strings.TrimSpace(query), " ")
+	if editing {
+		s := config.Setting(key)
+		if s == nil {
+			workflow.WarnEmpty("Unknown Setting: " + key)
+			return
+		}
+		value = strings.TrimSpace(value)
+		item := Item{
+			Title:        fmt.Sprintf("Set %s to %s", key, value),
+			Subtitle:     fmt.Sprintf("Currently %s  ·  %s", s.Value(), s.Help),
+			AutoComplete: key + " ",
+		}
+		if err := defaultConfig().Setting(key).Set(value); err != nil {
+			valid := false
+			item.Valid = &valid
+			item.Subtitle = err.Error()
+		} else {
+			item.SetVar("action", "setConfig")
+			item.SetVar("key", key)
+			item.SetVar("value", value)
+		}
+		workflow.AddItem(&item)
+		return
+	}
+	for _, err := range errs {
+		valid := false
+		workflow.AddItem(&Item{
+			Title:    err.Error(),
+			Subtitle: "Invalid config",
+			Valid:    &valid,
+			Icon:     &Icon{Path: os.Getenv("alfred_preferences") + "/resources/AlertCautionIcon.icns"},
+		})
+	}
+	// the keyword passes the query without filtering
+	query = strings.ToLower(key)
+	for _, s := range config.Settings() {
+		if !strings.Contains(strings.ToLower(s.Key+" "+s.Help), query) {
+			continue
+		}
+		value := s.Value()
+		if strings.HasSuffix(s.Key, "secret") && value != "" {
+			value = "••••••••"
+		}
+		item := Item{
+			Title:        fmt.Sprintf("%s = %s", s.Key, value),
+			Subtitle:     fmt.Sprintf("%s  ·  from %s", s.Help, s.Source),
+			AutoComplete: s.Key + " ",
+		}
+		item.Text.Copy = s.Value()
+		valid := false
+		item.Valid = &valid
+		if s.Source == "env" {
+			item.Subtitle += " " + s.EnvName()
+		}
+		// ⌘ reset to default
+		if s.Source == "file" {
+			cmd := &Mod{Subtitle: "Reset to default", Valid: true}
+			cmd.SetVar("action", "resetConfig")
+			cmd.SetVar("key", s.Key)
+			item.Mods.Cmd = cmd
+		}
+		workflow.AddItem(&item)
+	}
+	item := Item{
+		Title:    "Open Config File",
+		Subtitle: configPath(),
+	}
+	item.SetVar("action", "open")
+	item.SetVar("url", configPath())
+	workflow.AddItem(&item)
+}
+
+func ListPositionOptions(query string) {
+	valid := false
+	item := Item{
+		Title:    "Set Position",
+		Subtitle: "Type a timestamp: hh:mm:ss, mm:ss or seconds",
+		Valid:    &valid,
+	}
+	if query != "" {
+		if position, err := parseTimestamp(query); err != nil {
+			item.Subtitle = err.Error()
+		} else {
+			valid = true
+			item.Title = "Set Position to " + formatDuration(position)
+			item.Subtitle = ""
+			if position == 0 {
+				item.Title = "Reset Progress"
+			}
+			item.SetVar("action", "setPosition")
+			item.SetVar("position", fmt.Sprintf("%d", position))
+		}
+	}
+	workflow.AddItem(&item)
+}
+
+func ListFilters() {
+	filters, err := GetFilters(false)
+	if err != nil {
+		workflow.WarnEmpty(err.Error())
+		return
+	}
+	if len(filters) == 0 {
+		item := Item{
+			Title:    "No Filters Found",
+			Subtitle: "Refresh",
+			Icon:     &Icon{Path: "icons/refresh.png"},
+		}
+		item.SetVar("refresh", "filters")
+		workflow.AddItem(&item)
+		return
+	}
+	for _, f := range filters {
+		item := Item{
+			Title: f.Title,
+			Match: matchString(f.Title),
+		}
+		if episodes, err := f.GetEpisodes(false); err != nil {
+			item.Subtitle = err.Error()
+		} else {
+			item.Subtitle = fmt.Sprintf("%d episodes", len(episodes))
+		}
+		item.SetVar("trigger", "filters")
+		item.SetVar("filterUuid", f.UUID)
+		// ⇧⌘ refresh filters
+		cmdShift := &Mod{Subtitle: "Refresh filters", Icon: &Icon{Path: "icons/refresh.png"}}
+		cmdShift.SetVar("refresh", "filters")
+		item.Mods.CmdShift = cmdShift
+		workflow.AddItem(&item)
+	}
+}
+
+func ListFilterEpisodes(uuid string) {
+	f, err := GetFilter(uuid)
+	if err != nil {
+		workflow.WarnEmpty(err.Error())
+		return
+	}
+	episodes, err := f.GetEpisodes(false)
+	if err != nil {
+		workflow.WarnEmpty(err.Error())
+		return
+	}
+	_, _ = GetUpNext(false)
+	for i, e := range episodes {
+		if i == 100 {
+			break
+		}
+		item := e.Format(false)
+		item.Subtitle = fmt.Sprintf("􀪔 %s  ·  %s", e.Podcast, item.Subtitle)
+		// ⇧⌘ refresh filter
+		cmdShift := &Mod{Subtitle: "Refresh " + f.Title, Icon: &Icon{Path: "icons/refresh.png"}}
+		cmdShift.SetVar("refresh", "filter")
+		cmdShift.SetVar("filterUuid", f.UUID)
+		item.Mods.CmdShift = cmdShift
+		item.Mods.Shift.SetVar("prevTrigger", "filters")
+		workflow.AddItem(item)
+	}
+	if len(episodes) == 0 {
+		workflow.WarnEmpty("No Episodes Found")
+	} else {
+		item := Item{
+			Title:    fmt.Sprintf("%s: %d Episodes", f.Title, len(episodes)),
+			Subtitle: "Hold a modifier to act on all of them",
+		}
+		bulkMods(&item, episodes)
+		workflow.UnshiftItem(&item)
+	}
+	item := Item{
+		Title: "Go Back",
+		Icon:  &Icon{Path: "icons/back.png"},
+	}
+	item.SetVar("trigger", "filters")
+	item.SetVar("filterUuid", "")
+	workflow.AddItem(&item)
+	workflow.SetVar("filterUuid", f.UUID)
+}
+
+func ListPlaylists() {
+	playlists, err := GetPlaylists()
+	if err != nil {
+		workflow.WarnEmpty(err.Error())
+		return
+	}
+	for _, pl := range playlists {
+		item := Item{
+			Title:        pl.Name,
+			Subtitle:     pl.Rule.Source,
+			AutoComplete: pl.Name,
+			Match:        matchString(pl.Name),
+		}
+		item.SetVar("trigger", "playlist")
+		item.SetVar("playlist", pl.Name)
+		workflow.AddItem(&item)
+	}
+	if len(playlists) == 0 {
+		workflow.WarnEmpty("No Playlists Defined")
+	}
+}
+
+func ListPlaylist(name string) {
+	pl, err := GetPlaylist(name)
+	if err != nil {
+		workflow.WarnEmpty(err.Error())
+		return
+	}
+	episodes, err := pl.Episodes()
+	if err != nil {
+		workflow.WarnEmpty(err.Error())
+		return
+	}
+	_, _ = GetUpNext(false)
+	for i, e := range episodes {
+		if i == 100 {
+			break
+		}
+		item := e.Format(false)
+		item.Subtitle = fmt.Sprintf("􀪔 %s  ·  %s", e.Podcast, item.Subtitle)
+		item.Mods.Shift.SetVar("prevTrigger", "playlist")
+		workflow.AddItem(item)
+	}
+	if len(episodes) == 0 {
+		workflow.WarnEmpty("No Episodes Found")
+	} else {
+		var totalDuration int
+		for _, e := range episodes {
+			totalDuration += e.Duration - e.PlayedUpTo
+		}
+		item := Item{
+			Title:    fmt.Sprintf("%s: %d Episodes, %s", pl.Name, len(episodes), formatDuration(totalDuration)),
+			Subtitle: pl.Rule.Source,
+		}
+		// ⌥ replace playlist
+		alt := &Mod{Subtitle: "Replace playlist", Icon: &Icon{Path: "icons/play.png"}}
+		alt.SetVar("actionKeep", "playPlaylist")
+		item.Mods.Alt = alt
+
+		// ⌘ export to M3U
+		cmd := &Mod{Subtitle: "Export to M3U"}
+		cmd.SetVar("action", "exportPlaylist")
+		item.Mods.Cmd = cmd
+		bulkMods(&item, episodes)
+		workflow.UnshiftItem(&item)
+	}
+	item := Item{
+		Title: "Go Back",
+		Icon:  &Icon{Path: "icons/back.png"},
+	}
+	item.SetVar("trigger", "playlist")
+	item.SetVar("playlist", "")
+	workflow.AddItem(&item)
+	workflow.SetVar("playlist", pl.Name)
+}
+
 func upNextSummary(episodes []*Episode) {
 	if len(episodes) == 0 {
 		return
@@ -333,6 +1116,12 @@ func upNextSummary(episodes []*Episode) {
 	shift := &Mod{Subtitle: "Sync playlist", Icon: &Icon{Path: "icons/sync.png"}}
 	shift.SetVar("action", "sync")
 	item.Mods.Shift = shift
+
+	// ⌃ edit queue
+	ctrl := &Mod{Subtitle: "Edit queue…"}
+	ctrl.SetVar("trigger", "queue_edit")
+	ctrl.SetVar("uuid", "")
+	item.Mods.Ctrl = ctrl
 	workflow.UnshiftItem(&item)
 }
 
@@ -380,3 +1169,260 @@ func Search(query string) error {
 	workflow.SetVar("prevTrigger", "search")
 	return nil
 }
+
+// ListShare offers the share formats of an episode
+func ListShare(e *Episode) {
+	if e == nil {
+		workflow.WarnEmpty("Episode Not Found")
+		return
+	}
+	position := e.sharePosition()
+	titles := map[string]string{
+		"url":       "Link",
+		"timestamp": "Link at " + formatTimestamp(position),
+		"markdown":  "Markdown Link",
+		"rich":      "Snippet",
+	}
+	for _, format := range shareFormats {
+		if format == "timestamp" && position <= 0 {
+			continue
+		}
+		text, _ := e.ShareText(format, position)
+		item := Item{
+			Title:    titles[format],
+			Subtitle: strings.ReplaceAll(text, "\n", "  ·  "),
+			Arg:      text,
+		}
+		item.Text.Copy = text
+		item.Text.LargeType = text
+		item.SetVar("action", "share")
+		item.SetVar("format", format)
+		item.SetVar("position", fmt.Sprintf("%d", position))
+		item.SetVar("uuid", e.UUID)
+		item.SetVar("podcastUuid", e.PodcastUUID)
+		workflow.AddItem(&item)
+	}
+}
+
+// ListResolved offers to play, queue or subscribe to whatever a pasted
+// link points to
+func ListResolved(link string) {
+	if strings.TrimSpace(link) == "" {
+		valid := false
+		workflow.AddItem(&Item{
+			Title:    "Open Link",
+			Subtitle: "Paste a link from Pocket Casts, Apple Podcasts, Overcast, Castro, Podcast Addict, an episode or a feed",
+			Valid:    &valid,
+		})
+		return
+	}
+	r, err := ResolveURL(link)
+	if err != nil {
+		workflow.WarnEmpty(err.Error())
+		return
+	}
+	_ = GetPodcastList(false)
+	if r.Episode != nil {
+		_, _ = GetUpNext(false)
+		item := r.Episode.Format(false)
+		item.Subtitle = fmt.Sprintf("􀪔 %s  ·  %s", r.Episode.Podcast, item.Subtitle)
+		if r.Position > 0 {
+			item.Subtitle += "  ·  Shared at " + formatTimestamp(r.Position)
+			// ⌥ play from the shared start time
+			item.Mods.Alt.Subtitle = "Play now from " + formatTimestamp(r.Position)
+			item.Mods.Alt.SetVar("position", fmt.Sprintf("%d", r.Position))
+		}
+		workflow.AddItem(item)
+	}
+	if r.Podcast != nil {
+		workflow.AddItem(r.Podcast.Format(true))
+	}
+}
+
+// ListDiscover browses the discover sections, a category list
+// (`discoverType` categories), a podcast list (podcast_list) or the Podcast
+// Index trending podcasts (podcast_index). Podcast lists opened from a
+// category go back to `categories`.
+func ListDiscover(discoverType, source, categories string) {
+	layout, err := GetDiscoverLayout(false)
+	if err != nil {
+		workflow.WarnEmpty(err.Error())
+		return
+	}
+	region := layout.Region()
+	refresh := &Mod{Subtitle: "Refresh discover", Icon: &Icon{Path: "icons/refresh.png"}}
+	refresh.SetVar("refresh", "discover")
+
+	switch discoverType {
+	case "categories":
+		list, err := GetDiscoverCategories(source, false)
+		if err != nil {
+			workflow.WarnEmpty(err.Error())
+			return
+		}
+		for _, c := range list {
+			item := Item{
+				Title: c.Name,
+				Match: matchString(c.Name),
+			}
+			item.SetVar("trigger", "discover")
+			item.SetVar("discoverType", "podcast_list")
+			item.SetVar("discoverSource", layout.regionSource(c.Source, region))
+			item.SetVar("discoverCategories", source)
+			item.Mods.CmdShift = refresh
+			workflow.AddItem(&item)
+		}
+		if len(list) == 0 {
+			workflow.WarnEmpty("No Categories Found")
+		}
+	case "podcast_list", "podcast_index":
+		var podcasts []*Podcast
+		if discoverType == "podcast_index" {
+			if pi := podcastIndex(); pi == nil {
+				err = fmt.Errorf("podcast index not configured")
+			} else {
+				podcasts, err = pi.Trending(50)
+			}
+			refresh = nil
+		} else if list, listErr := GetDiscoverList(source, false); listErr == nil {
+			podcasts = list.Podcasts
+		} else {
+			err = listErr
+		}
+		if err != nil {
+			workflow.WarnEmpty(err.Error())
+			return
+		}
+		_ = GetPodcastList(false)
+		for _, p := range podcasts {
+			item := p.Format(true)
+			item.Mods.CmdShift = refresh
+			workflow.AddItem(item)
+		}
+		if len(podcasts) == 0 {
+			workflow.WarnEmpty("No Podcasts Found")
+		}
+		// `ListEpisodes` goes back here
+		workflow.SetVar("prevTrigger", "discover")
+		workflow.SetVar("discoverType", discoverType)
+		workflow.SetVar("discoverSource", source)
+		workflow.SetVar("discoverCategories", categories)
+	default:
+		for _, s := range layout.Sections(region) {
+			item := Item{
+				Title:    s.Title,
+				Subtitle: "Podcasts",
+				Match:    matchString(s.Title),
+			}
+			if s.Type == "categories" {
+				item.Subtitle = "Categories"
+			}
+			if r, ok := layout.Regions[region]; ok {
+				item.Subtitle += "  ·  " + r.Name
+			}
+			item.SetVar("trigger", "discover")
+			item.SetVar("discoverType", s.Type)
+			item.SetVar("discoverSource", s.Source)
+			item.SetVar("discoverCategories", "")
+			item.Mods.CmdShift = refresh
+			workflow.AddItem(&item)
+		}
+		if podcastIndex() != nil {
+			item := Item{
+				Title:    "Trending on Podcast Index",
+				Subtitle: "Podcasts  ·  independent and non-English shows",
+				Match:    matchString("Trending Podcast Index"),
+			}
+			item.SetVar("trigger", "discover")
+			item.SetVar("discoverType", "podcast_index")
+			item.SetVar("discoverSource", "")
+			item.SetVar("discoverCategories", "")
+			workflow.AddItem(&item)
+		}
+		return
+	}
+
+	item := Item{
+		Title: "Go Back",
+		Icon:  &Icon{Path: "icons/back.png"},
+	}
+	item.SetVar("trigger", "discover")
+	if discoverType == "podcast_list" && categories != "" {
+		item.SetVar("discoverType", "categories")
+		item.SetVar("discoverSource", categories)
+	} else {
+		item.SetVar("discoverType", "")
+		item.SetVar("discoverSource", "")
+	}
+	item.SetVar("discoverCategories", "")
+	workflow.AddItem(&item)
+}
+
+// ListFeedEpisodes lists the episodes of a podcast found outside Pocket
+// Casts, through the Podcast Index, until it is subscribed to by its feed
+func ListFeedEpisodes(feed string, goBackTo string) {
+	pi := podcastIndex()
+	if pi == nil {
+		workflow.WarnEmpty("Podcast Index Not Configured")
+		return
+	}
+	episodes, err := pi.EpisodesByFeed(feed, config.Episodes.PageSize)
+	if err != nil {
+		workflow.WarnEmpty(err.Error())
+		return
+	}
+	// ⌘ subscribe
+	cmd := &Mod{Subtitle: "Subscribe", Icon: &Icon{Path: "icons/plus.png"}}
+	cmd.SetVar("actionKeep", "subscribe")
+	cmd.SetVar("podcastUuid", "")
+	cmd.SetVar("feedUrl", feed)
+	for _, e := range episodes {
+		item := Item{
+			Title:        e.Title,
+			Subtitle:     fmt.Sprintf("􀉉 %s  ·  􀖈 %s", e.Date.Format("Mon, 2006-01-02"), formatDuration(e.Duration)),
+			Arg:          e.URL,
+			QuickLookURL: e.URL,
+			Match:        matchString(e.Title),
+		}
+		item.Text.LargeType = stripHTML(e.ShowNotes)
+		// ↵ open the enclosure
+		item.SetVar("action", "open")
+		item.SetVar("url", e.URL)
+		item.Mods.Cmd = cmd
+		workflow.AddItem(&item)
+	}
+	if len(episodes) == 0 {
+		workflow.WarnEmpty("No Episodes Found")
+	}
+	item := Item{
+		Title: "Go Back",
+		Icon:  &Icon{Path: "icons/back.png"},
+	}
+	item.SetVar("trigger", goBackTo)
+	item.SetVar("feedUrl", "")
+	workflow.AddItem(&item)
+}
+
+// bulkMods adds the state actions for all `episodes` to a summary item, which
+// passes them on as `selection`
+func bulkMods(item *Item, episodes []*Episode) {
+	lines := make([]string, len(episodes))
+	for i, e := range episodes {
+		lines[i] = e.PodcastUUID + "/" + e.UUID
+	}
+	selection := strings.Join(lines, "\n")
+	mod := func(subtitle, action string) *Mod {
+		m := &Mod{Subtitle: fmt.Sprintf("%s: %d episodes", subtitle, len(episodes))}
+		m.SetVar("action", action)
+		m.SetVar("selection", selection)
+		return m
+	}
+	// ⌃ mark all as played
+	item.Mods.Ctrl = mod("Mark as played", "markAsPlayed")
+	// ⇧⌃ mark all as unplayed
+	item.Mods.CtrlShift = mod("Mark as unplayed", "markAsUnplayed")
+	// ⇧⌥ reset progress of all
+	item.Mods.AltShift = mod("Reset progress", "resetProgress")
+	// fn unarchive all
+	item.Mods.Fn = mod("Unarchive", "unarchive")
+}
@@ -41,6 +41,10 @@ func TestPodcast_ListEpisodes(t *testing.T) {
 		name string // description of this test case
 		// Named input parameters for receiver constructor.
 		podcast main.Podcast
+		// Named input parameters for target function.
+		query        string
+		offset       int
+		showArchived bool
 	}{
 		{
 			name: "ListEpisodes",
@@ -48,6 +52,27 @@ func TestPodcast_ListEpisodes(t *testing.T) {
 				Name: "The Daily",
 			},
 		},
+		{
+			name: "ListEpisodes second page",
+			podcast: main.Podcast{
+				Name: "The Daily",
+			},
+			offset: 30,
+		},
+		{
+			name: "ListEpisodes oldest with query",
+			podcast: main.Podcast{
+				Name: "The Daily",
+			},
+			query: "old: trump",
+		},
+		{
+			name: "ListEpisodes with archived",
+			podcast: main.Podcast{
+				Name: "The Daily",
+			},
+			showArchived: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -55,7 +80,7 @@ func TestPodcast_ListEpisodes(t *testing.T) {
 				t.Errorf("Podcast.ListEpisodes() error = %v", err)
 				return
 			}
-			tt.podcast.ListEpisodes("podcasts")
+			tt.podcast.ListEpisodes("podcasts", tt.query, tt.offset, tt.showArchived)
 		})
 	}
 }
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/exec"
+	"strconv"
+	"strings"
 )
 
 var (
@@ -29,6 +32,78 @@ func setup() {
 			log.Fatal(err)
 		}
 	}
+	if _, err := os.Stat(cacheDir + "/chapters"); os.IsNotExist(err) {
+		if err = os.MkdirAll(cacheDir+"/chapters", 0o755); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(cacheDir + "/transcripts"); os.IsNotExist(err) {
+		if err = os.MkdirAll(cacheDir+"/transcripts", 0o755); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(cacheDir + "/discover"); os.IsNotExist(err) {
+		if err = os.MkdirAll(cacheDir+"/discover", 0o755); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// selectedEpisodes returns the episode given by `uuid` and `podcastUuid`, or
+// every `podcastUuid/uuid` line of `selection` for bulk actions
+func selectedEpisodes() []*Episode {
+	pairs := [][2]string{}
+	if selection := os.Getenv("selection"); selection != "" {
+		pairs = parseSelection(selection)
+	} else if os.Getenv("uuid") != "" {
+		pairs = append(pairs, [2]string{os.Getenv("podcastUuid"), os.Getenv("uuid")})
+	}
+	return resolveEpisodes(pairs)
+}
+
+// parseSelection reads `podcastUuid/uuid` lines
+func parseSelection(selection string) [][2]string {
+	pairs := [][2]string{}
+	for line := range strings.FieldsSeq(selection) {
+		if podcastUUID, uuid, ok := strings.Cut(line, "/"); ok {
+			pairs = append(pairs, [2]string{podcastUUID, uuid})
+		}
+	}
+	return pairs
+}
+
+// resolveEpisodes looks up `[podcastUuid, uuid]` pairs in the cache
+func resolveEpisodes(pairs [][2]string) []*Episode {
+	episodes := make([]*Episode, 0, len(pairs))
+	for _, pair := range pairs {
+		e := &Episode{UUID: pair[1], PodcastUUID: pair[0]}
+		p := &Podcast{UUID: pair[0]}
+		if err := p.GetEpisodes(false); err == nil {
+			if _e, ok := p.EpisodeMap[e.UUID]; ok {
+				e = _e
+			}
+		}
+		episodes = append(episodes, e)
+	}
+	return episodes
+}
+
+// targetEpisode is the selected episode, or the one playing in the player
+func targetEpisode() *Episode {
+	if episodes := selectedEpisodes(); len(episodes) > 0 {
+		return episodes[0]
+	}
+	if e, err := currentEpisode(); err == nil {
+		return e
+	}
+	return nil
+}
+
+func describeEpisodes(episodes []*Episode) string {
+	if len(episodes) == 1 && episodes[0].Title != "" {
+		return episodes[0].Title
+	}
+	return fmt.Sprintf("%d episodes", len(episodes))
 }
 
 func performAction(action string) {
@@ -43,11 +118,26 @@ func performAction(action string) {
 		}
 		_ = p.GetEpisodes(false)
 		if e, ok := p.EpisodeMap[os.Getenv("uuid")]; ok {
+			// the start time of a shared link
+			position := atoi(os.Getenv("position"))
+			if position > 0 && action == "play_now" && config.Player.SyncSharedPosition {
+				if err := e.Update(map[string]any{
+					"position": fmt.Sprintf("%d", position),
+					"status":   statusInProgress,
+				}); err != nil {
+					Notify(err.Error(), "Error")
+				}
+			}
 			if _, err := e.AddToQueue(action); err != nil {
 				Notify(err.Error(), "Error")
 			} else if action == "play_now" {
 				if playlist, err := ExportPlaylist(); err == nil {
 					_ = loadPlaylist(playlist, "replace")
+					if position > 0 {
+						if err := seekWhenLoaded(e.URL, position); err != nil {
+							Notify(err.Error(), "Error")
+						}
+					}
 				}
 			} else {
 				Notify("Added to queue: " + e.Title)
@@ -60,16 +150,148 @@ func performAction(action string) {
 			Notify(err.Error(), "Error")
 		}
 	case "markAsPlayed", "archive":
-		e := &Episode{UUID: os.Getenv("uuid"), PodcastUUID: os.Getenv("podcastUuid")}
-		if err := e.Archive(action == "markAsPlayed"); err != nil {
+		episodes := selectedEpisodes()
+		if err := ArchiveEpisodes(episodes, action == "markAsPlayed"); err != nil {
 			Notify(err.Error(), "Error")
 		} else if action == "markAsPlayed" {
-			Notify("Marked as played: " + e.Title)
+			Notify("Marked as played: " + describeEpisodes(episodes))
+		} else {
+			Notify("Archived: " + describeEpisodes(episodes))
+		}
+	case "unarchive":
+		episodes := selectedEpisodes()
+		if err := UnarchiveEpisodes(episodes); err != nil {
+			Notify(err.Error(), "Error")
+		} else {
+			Notify("Unarchived: " + describeEpisodes(episodes))
+		}
+	case "markAsUnplayed":
+		episodes := selectedEpisodes()
+		if err := MarkEpisodesUnplayed(episodes); err != nil {
+			Notify(err.Error(), "Error")
+		} else {
+			Notify("Marked as unplayed: " + describeEpisodes(episodes))
+		}
+	case "resetProgress", "setPosition":
+		position := 0
+		if action == "setPosition" {
+			var err error
+			if position, err = parseTimestamp(os.Getenv("position")); err != nil {
+				Notify(err.Error(), "Error")
+				return
+			}
+		}
+		episodes := selectedEpisodes()
+		if err := SetEpisodesPosition(episodes, position); err != nil {
+			Notify(err.Error(), "Error")
+		} else if action == "resetProgress" {
+			Notify("Progress reset: " + describeEpisodes(episodes))
+		} else {
+			Notify(fmt.Sprintf("Position set to %s: %s", formatDuration(position), describeEpisodes(episodes)))
+		}
+	case "moveToTop", "moveUp", "moveDown", "moveToBottom", "removeFromQueue",
+		"clearQueue", "removePlayed", "dedupeQueue", "shuffleQueue":
+		if _, err := EditQueue(action, selectedEpisodes()); err != nil {
+			Notify(err.Error(), "Error")
+		}
+	case "fill_queue":
+		target, _ := strconv.Atoi(os.Getenv("target"))
+		if added, err := FillQueue(target); err != nil {
+			Notify(err.Error(), "Error")
+		} else if len(added) == 0 {
+			Notify("Nothing to add")
+		} else {
+			Notify("Added to queue: " + describeEpisodes(added))
+		}
+	case "exportStats":
+		if file, err := ExportStats(os.Getenv("format")); err != nil {
+			Notify(err.Error(), "Error")
+		} else if err := openURL(file); err != nil {
+			Notify(err.Error(), "Error")
+		}
+	case "setConfig", "resetConfig":
+		key := os.Getenv("key")
+		var err error
+		if action == "setConfig" {
+			err = SetConfigValue(key, os.Getenv("value"))
+		} else {
+			err = ResetConfigValue(key)
+		}
+		if err != nil {
+			Notify(err.Error(), "Error")
+		} else if action == "setConfig" {
+			Notify(fmt.Sprintf("%s set to %s", key, os.Getenv("value")))
+		} else {
+			Notify(key + " reset")
+		}
+	case "share":
+		e := targetEpisode()
+		if e == nil {
+			Notify("Episode not found", "Error")
+			return
+		}
+		text, err := e.ShareText(os.Getenv("format"), atoi(os.Getenv("position")))
+		if err == nil {
+			err = copyToClipboard(text)
+		}
+		if err != nil {
+			Notify(err.Error(), "Error")
+		} else {
+			Notify("Copied to clipboard", e.Title)
+		}
+	case "open":
+		if err := openURL(os.Getenv("url")); err != nil {
+			Notify(err.Error(), "Error")
+		}
+	case "copy":
+		if err := copyToClipboard(os.Getenv("text")); err != nil {
+			Notify(err.Error(), "Error")
+		} else {
+			Notify("Copied to clipboard")
+		}
+	case "seek":
+		episode, position, err := parseSeekArg(os.Getenv("position"))
+		if err != nil {
+			Notify(err.Error(), "Error")
+			return
+		}
+		// a timestamp of the show notes is only for its own episode
+		if _, uuid, ok := strings.Cut(episode, "/"); ok {
+			if c, err := currentEpisode(); err != nil || c.UUID != uuid {
+				Notify("The episode of this timestamp is not playing", "Error")
+				return
+			}
+		}
+		if err := SeekTo(float64(position)); err != nil {
+			Notify(err.Error(), "Error")
+		}
+	case "playPlaylist", "exportPlaylist":
+		pl, err := GetPlaylist(os.Getenv("playlist"))
+		if err != nil {
+			Notify(err.Error(), "Error")
+			return
+		}
+		if action == "exportPlaylist" {
+			if file, err := pl.Export(); err != nil {
+				Notify(err.Error(), "Error")
+			} else {
+				_ = exec.Command("/usr/bin/open", "-R", file).Run()
+			}
+			return
+		}
+		episodes, err := pl.Episodes()
+		if err != nil {
+			Notify(err.Error(), "Error")
+			return
+		}
+		// the player's playlist has to be the one `readPlaylist` knows
+		if file, err := writePlaylist(episodes, getCachePath("podcast_playlist.m3u")); err != nil {
+			Notify(err.Error(), "Error")
 		} else {
-			Notify("Archived: " + e.Title)
+			_ = loadPlaylist(file, "replace")
 		}
 	case "subscribe":
-		p := &Podcast{UUID: os.Getenv("podcastUuid"), Name: os.Getenv("podcast")}
+		p := &Podcast{UUID: os.Getenv("podcastUuid"), Name: os.Getenv("podcast"), URL: os.Getenv("feedUrl")}
 		if err := p.Subscribe(); err != nil {
 			Notify(err.Error(), "Error")
 		} else {
@@ -103,30 +325,106 @@ func runTrigger(trigger string) {
 	case "latest":
 		ListNewReleases()
 	case "episodes":
+		if os.Getenv("podcastUuid") == "" && os.Getenv("feedUrl") != "" {
+			ListFeedEpisodes(os.Getenv("feedUrl"), os.Getenv("prevTrigger"))
+			break
+		}
 		p := &Podcast{UUID: os.Getenv("podcastUuid")}
 		_ = p.GetEpisodes(false)
 		goBackTo := os.Getenv("prevTrigger")
-		p.ListEpisodes(goBackTo)
+		// paging keeps the query it was opened with
+		query := os.Getenv("episodeQuery")
+		if len(os.Args) > 1 && os.Args[1] != "" {
+			query = os.Args[1]
+		}
+		offset, _ := strconv.Atoi(os.Getenv("offset"))
+		p.ListEpisodes(goBackTo, query, offset, os.Getenv("showArchived") != "")
+	case "filters":
+		if uuid := os.Getenv("filterUuid"); uuid != "" {
+			ListFilterEpisodes(uuid)
+		} else {
+			ListFilters()
+		}
 	case "queue":
 		ListUpNext()
+	case "fill_queue":
+		query := ""
+		if len(os.Args) > 1 {
+			query = os.Args[1]
+		}
+		ListQueueFill(query)
+	case "settings":
+		query := ""
+		if len(os.Args) > 1 {
+			query = os.Args[1]
+		}
+		ListSettings(query, configErrs)
+	case "share":
+		ListShare(targetEpisode())
+	case "open_url":
+		link := ""
+		if len(os.Args) > 1 {
+			link = os.Args[1]
+		}
+		ListResolved(link)
+	case "stats":
+		ListStats()
+	case "queue_edit":
+		ListQueueActions(os.Getenv("uuid"))
 	case "playing":
 		GetPlaying()
+	case "chapters":
+		ListChapters(targetEpisode())
+	case "links":
+		ListLinks(targetEpisode())
+	case "transcript_search":
+		query := ""
+		if len(os.Args) > 1 {
+			query = os.Args[1]
+		}
+		ListTranscriptSearch(targetEpisode(), query)
+	case "position":
+		position := ""
+		if len(os.Args) > 1 {
+			position = os.Args[1]
+		}
+		ListPositionOptions(position)
+	case "playlist":
+		query := ""
+		if len(os.Args) > 1 {
+			query = os.Args[1]
+		}
+		if name := os.Getenv("playlist"); name != "" {
+			ListPlaylist(name)
+		} else if _, err := GetPlaylist(query); err == nil {
+			ListPlaylist(query)
+		} else {
+			ListPlaylists()
+		}
 	case "search":
 		term := ""
 		if len(os.Args) > 1 {
 			term = os.Args[1]
 		}
 		_ = Search(term)
+	case "discover":
+		ListDiscover(os.Getenv("discoverType"), os.Getenv("discoverSource"), os.Getenv("discoverCategories"))
+	case "episode_search":
+		term := ""
+		if len(os.Args) > 1 {
+			term = os.Args[1]
+		}
+		ListSearchResults(term)
 	case "episode_info":
 		shareURL := ""
 		if len(os.Args) > 1 {
 			shareURL = os.Args[1]
 		}
-		episode, err := GetEpisodeByURL(shareURL)
+		r, err := ResolveURL(shareURL)
 		if err != nil {
 			log.Fatal(err)
 		}
-		jsonStr, err := episode.JSON()
+		jsonStr, err := r.JSON()
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -137,8 +435,21 @@ func runTrigger(trigger string) {
 	}
 }
 
+// configErrs are the problems found while loading the config
+var configErrs []error
+
 func main() {
+	if isCLI() {
+		setupCLI()
+		setup()
+		config, configErrs = LoadConfig()
+		for _, err := range configErrs {
+			fmt.Fprintf(os.Stderr, "Config: %v\n", err)
+		}
+		os.Exit(RunCLI(os.Args[1:], os.Stdout))
+	}
 	setup()
+	config, configErrs = LoadConfig()
 
 	trigger := os.Getenv("trigger")
 	action := os.Getenv("action")
@@ -146,21 +457,42 @@ func main() {
 		action = os.Getenv("actionKeep")
 	}
 
+	if os.Getenv("refresh") != "" || action != "" {
+		for _, err := range configErrs {
+			fmt.Fprintf(os.Stderr, "Config: %v\n", err)
+		}
+	}
 	if os.Getenv("refresh") != "" {
-		if err := refreshCache([]string{os.Getenv("refresh"), os.Getenv("podcastUuid")}); err != nil {
+		id := os.Getenv("podcastUuid")
+		target := []string{os.Getenv("refresh"), id}
+		if os.Getenv("refresh") == "filter" {
+			target[1] = os.Getenv("filterUuid")
+		} else if os.Getenv("refresh") == "episode_artworks" {
+			target[1] = os.Getenv("selection")
+		}
+		if err := refreshCache(target); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		}
 		fmt.Println(`{"alfredworkflow":{"variables":{"refresh":""}}}`)
 		return
 	} else if action != "" {
 		performAction(action)
-		fmt.Println(`{"alfredworkflow":{"variables":{"action":""}}}`)
+		fmt.Println(`{"alfredworkflow":{"variables":{"action":"","selection":""}}}`)
 		return
 	}
 
 	workflow.SetVar("trigger", trigger)
+	// items set `withQuery` to open a view that reads the typed query
+	workflow.SetVar("withQuery", "")
+	workflow.SetVar("episodeQuery", "")
+	// only the item that sets `selection` acts on it
+	workflow.SetVar("selection", "")
 
 	runTrigger(trigger)
+	if trigger != "settings" {
+		configWarnings(configErrs)
+	}
+	fetchMissingArtworks()
 
 	workflow.Output()
 }
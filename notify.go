@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// more new episodes than this are summed up in one notification
+	maxEpisodeNotifications = 3
+	// older episodes are never announced, e.g. the back catalog of a new subscription
+	notifyMaxAge = 3 * 24 * time.Hour
+	// seen episodes are forgotten after this
+	seenRetention = 90 * 24 * time.Hour
+)
+
+// notifyPodcasts reads `notify` of the config file and the `notifyPodcasts`
+// workflow config: podcast names or UUIDs, one per line, or `*` for all podcasts
+func notifyPodcasts() map[string]bool {
+	podcasts := make(map[string]bool)
+	for _, name := range config.Notify {
+		podcasts[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	for line := range strings.Lines(os.Getenv("notifyPodcasts")) {
+		if line = strings.ToLower(strings.TrimSpace(line)); line != "" {
+			podcasts[line] = true
+		}
+	}
+	return podcasts
+}
+
+func watched(podcasts map[string]bool, e *Episode) bool {
+	return podcasts["*"] || podcasts[strings.ToLower(e.Podcast)] || podcasts[strings.ToLower(e.PodcastUUID)]
+}
+
+// NewEpisodes returns the episodes of watched podcasts missing from `seen`,
+// and adds every episode to it
+func NewEpisodes(episodes []*Episode, seen map[string]time.Time, podcasts map[string]bool, now time.Time) []*Episode {
+	newEpisodes := make([]*Episode, 0)
+	for _, e := range episodes {
+		if _, ok := seen[e.UUID]; ok {
+			continue
+		}
+		seen[e.UUID] = now
+		if watched(podcasts, e) && now.Sub(e.Date) < notifyMaxAge && !e.IsPlayed() {
+			newEpisodes = append(newEpisodes, e)
+		}
+	}
+	for uuid, t := range seen {
+		if now.Sub(t) > seenRetention {
+			delete(seen, uuid)
+		}
+	}
+	sort.Slice(newEpisodes, func(i, j int) bool {
+		return newEpisodes[i].Date.Before(newEpisodes[j].Date)
+	})
+	return newEpisodes
+}
+
+// notifyNewEpisodes diffs `episodes` against the persisted "last seen" set.
+// The first run only records what is there.
+func notifyNewEpisodes(episodes []*Episode) error {
+	podcasts := notifyPodcasts()
+	if len(podcasts) == 0 {
+		return nil
+	}
+	file := getCachePath("last_seen")
+	// another refresh is on it; whatever it misses is caught next time
+	lock, err := os.OpenFile(file+".lock", os.O_CREATE|os.O_EXCL, 0o666)
+	if err != nil {
+		if info, statErr := os.Stat(file + ".lock"); statErr == nil && time.Since(info.ModTime()) > time.Minute {
+			_ = os.Remove(file + ".lock")
+		}
+		return nil
+	}
+	_ = lock.Close()
+	defer func() { _ = os.Remove(file + ".lock") }()
+	seen := make(map[string]time.Time)
+	data, err := os.ReadFile(file)
+	firstRun := os.IsNotExist(err)
+	if err == nil {
+		_ = json.Unmarshal(data, &seen)
+	}
+	newEpisodes := NewEpisodes(episodes, seen, podcasts, time.Now())
+	data, _ = json.Marshal(seen)
+	if err := writeCache(file, data); err != nil {
+		return err
+	}
+	if firstRun || len(newEpisodes) == 0 {
+		return nil
+	}
+	if len(newEpisodes) > maxEpisodeNotifications {
+		names := make([]string, 0)
+		counted := make(map[string]bool)
+		for _, e := range newEpisodes {
+			if !counted[e.Podcast] {
+				counted[e.Podcast] = true
+				names = append(names, e.Podcast)
+			}
+		}
+		Notify(strings.Join(names, ", "), fmt.Sprintf("%d New Episodes", len(newEpisodes)))
+		return nil
+	}
+	for _, e := range newEpisodes {
+		NotifyEpisode(e)
+	}
+	return nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// episodeCommand runs this workflow with `action` on the episode
+func episodeCommand(e *Episode, action string) string {
+	executable, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	wd, _ := os.Getwd()
+	return fmt.Sprintf("cd %s && action=%s uuid=%s podcastUuid=%s alfred_workflow_cache=%s %s",
+		shellQuote(wd), action, shellQuote(e.UUID), shellQuote(e.PodcastUUID), shellQuote(cacheDir), shellQuote(executable))
+}
+
+// NotifyEpisode announces a new episode with the podcast artwork. Clicking it
+// plays the episode; with `alerter` installed there are "Play Now" and "Queue"
+// buttons as well.
+func NotifyEpisode(e *Episode) {
+	image := getCachePath("artworks", e.PodcastUUID)
+	if _, err := os.Stat(image); err != nil {
+		image, _ = filepath.Abs("icon.png")
+	}
+	if _, err := exec.LookPath("alerter"); err == nil {
+		scpt := fmt.Sprintf(`r=$(alerter -title %s -message %s -contentImage %s -actions 'Play Now,Queue' -closeLabel Dismiss -timeout 3600)
+case "$r" in
+	"Play Now"|@CONTENTCLICKED) %s ;;
+	Queue) %s ;;
+esac`,
+			shellQuote(e.Podcast), shellQuote(e.Title), shellQuote(image),
+			episodeCommand(e, "play_now"), episodeCommand(e, "play_last"))
+		// alerter waits for the user, so leave it running on its own
+		cmd := exec.Command("/bin/sh", "-c", scpt)
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Setsid: true,
+		}
+		if err := cmd.Start(); err == nil {
+			return
+		}
+	}
+	cmd := exec.Command("terminal-notifier", "-title", e.Podcast, "-message", e.Title,
+		"-sender", "com.runningwithcrayons.Alfred", "-contentImage", image, "-group", e.UUID)
+	if command := episodeCommand(e, "play_now"); command != "" {
+		cmd.Args = append(cmd.Args, "-execute", command)
+	}
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s]: notification: %s\n", e.Title, err)
+	}
+}
@@ -0,0 +1,50 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/twio142/alfred-podcasts"
+)
+
+func TestNewEpisodes(t *testing.T) {
+	now := time.Now()
+	episodes := []*main.Episode{
+		{UUID: "seen", Podcast: "Daily", Date: now},
+		{UUID: "new", Podcast: "Daily", Date: now.Add(-time.Hour)},
+		{UUID: "old", Podcast: "Daily", Date: now.AddDate(0, -1, 0)},
+		{UUID: "other", Podcast: "Other", PodcastUUID: "p2", Date: now},
+	}
+	tests := []struct {
+		name     string // description of this test case
+		podcasts map[string]bool
+		want     []string
+	}{
+		{name: "watched podcast", podcasts: map[string]bool{"daily": true}, want: []string{"new"}},
+		{name: "by uuid", podcasts: map[string]bool{"p2": true}, want: []string{"other"}},
+		{name: "all podcasts", podcasts: map[string]bool{"*": true}, want: []string{"new", "other"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := map[string]time.Time{"seen": now, "expired": now.AddDate(-1, 0, 0)}
+			got := main.NewEpisodes(episodes, seen, tt.podcasts, now)
+			if len(got) != len(tt.want) {
+				t.Fatalf("NewEpisodes() returned %d episodes, want %d", len(got), len(tt.want))
+			}
+			for i, e := range got {
+				if e.UUID != tt.want[i] {
+					t.Errorf("NewEpisodes()[%d] = %s, want %s", i, e.UUID, tt.want[i])
+				}
+			}
+			if _, ok := seen["old"]; !ok {
+				t.Error("episodes should be marked as seen")
+			}
+			if _, ok := seen["expired"]; ok {
+				t.Error("expired episodes should be forgotten")
+			}
+			if again := main.NewEpisodes(episodes, seen, tt.podcasts, now); len(again) != 0 {
+				t.Errorf("NewEpisodes() announced %d episodes twice", len(again))
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NowPlaying is what a player reports about its current track
+type NowPlaying struct {
+	Source   string
+	URL      string
+	Title    string
+	Artist   string
+	Album    string
+	Position float64 // seconds
+	Paused   bool
+}
+
+type nowPlayingProvider interface {
+	Name() string
+	NowPlaying() (*NowPlaying, error)
+}
+
+// asked in order; nowplaying-cli reads the system media session, which may
+// belong to any app, so it only answers when no player does
+var nowPlayingProviders = []nowPlayingProvider{
+	mpvProvider{},
+	mprisProvider{},
+	nowPlayingCLIProvider{},
+}
+
+// GetNowPlaying returns the episode of the first player that plays one
+func GetNowPlaying() (*Episode, *NowPlaying, error) {
+	return getNowPlaying(true)
+}
+
+// getNowPlaying looks the episode up in all subscribed podcasts only with
+// `library`, which is too slow for polling
+func getNowPlaying(library bool) (*Episode, *NowPlaying, error) {
+	errs := make([]string, 0)
+	for _, p := range nowPlayingProviders {
+		np, err := p.NowPlaying()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", p.Name(), err))
+			continue
+		}
+		if e := np.episode(library); e != nil {
+			return e, np, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: no episode for %q", p.Name(), np.Title))
+	}
+	return nil, nil, fmt.Errorf("nothing playing (%s)", strings.Join(errs, "; "))
+}
+
+// Episode maps the track to an episode by its URL; titles are only trusted
+// when the player does not tell the URL
+func (np *NowPlaying) Episode() *Episode {
+	return np.episode(true)
+}
+
+func (np *NowPlaying) episode(library bool) *Episode {
+	if np.URL != "" {
+		return findEpisodeByURL(np.URL, library)
+	}
+	if np.Title == "" {
+		return nil
+	}
+	if !library {
+		return FindEpisode(map[string]string{"title": np.Title})
+	}
+	return FindEpisode(map[string]string{"title": np.Title, "podcast": np.Album, "author": np.Artist})
+}
+
+// stripTimestamp removes the `t` parameter added by `writePlaylist`
+func stripTimestamp(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.RawQuery == "" {
+		return u
+	}
+	q := parsed.Query()
+	q.Del("t")
+	parsed.RawQuery = q.Encode()
+	return parsed.String()
+}
+
+// episodeByURL looks the URL up in the exported playlist, Up Next and the
+// cached episodes. Local files match the file name of the enclosure.
+func episodeByURL(u string) *Episode {
+	return findEpisodeByURL(u, true)
+}
+
+// findEpisodeByURL skips the subscribed podcasts without `library`
+func findEpisodeByURL(u string, library bool) *Episode {
+	if episodeMap, err := readPlaylist(); err == nil {
+		if e, ok := episodeMap[u]; ok {
+			return e
+		}
+	}
+	u = stripTimestamp(strings.TrimPrefix(u, "file://"))
+	local := !strings.Contains(u, "://")
+	same := func(e *Episode) bool {
+		if e.URL == "" {
+			return false
+		}
+		if local {
+			enclosure, err := url.Parse(e.URL)
+			return err == nil && path.Base(enclosure.Path) == path.Base(u)
+		}
+		return stripTimestamp(e.URL) == u
+	}
+	if episodes, err := GetUpNext(false); err == nil {
+		for _, e := range episodes {
+			if same(e) {
+				return e
+			}
+		}
+	}
+	if !library {
+		return nil
+	}
+	if err := GetAllPodcasts(false); err != nil {
+		return nil
+	}
+	for _, p := range podcastMap {
+		for _, e := range p.EpisodeMap {
+			if same(e) {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// mpvProvider asks IINA or mpv over the IPC socket
+type mpvProvider struct{}
+
+func (mpvProvider) Name() string {
+	return "mpv"
+}
+
+func (mpvProvider) NowPlaying() (*NowPlaying, error) {
+	filename, err := runCommand("get_property", "path")
+	if err != nil {
+		return nil, err
+	}
+	np := &NowPlaying{Source: "mpv"}
+	if np.URL, _ = filename.(string); np.URL == "" {
+		return nil, fmt.Errorf("no file playing")
+	}
+	if title, err := runCommand("get_property", "media-title"); err == nil {
+		np.Title, _ = title.(string)
+	}
+	if paused, err := runCommand("get_property", "pause"); err == nil {
+		np.Paused, _ = paused.(bool)
+	}
+	np.Position, _ = getPosition()
+	return np, nil
+}
+
+// mprisProvider asks the MPRIS players on the D-Bus session bus, preferring
+// one that is playing
+type mprisProvider struct{}
+
+func (mprisProvider) Name() string {
+	return "mpris"
+}
+
+func dbusSend(dest, objectPath, method string, args ...string) (string, error) {
+	cmd := exec.Command("dbus-send", append([]string{"--session", "--print-reply", "--dest=" + dest, objectPath, method}, args...)...)
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+func mprisProperty(player, property string) (string, error) {
+	return dbusSend(player, "/org/mpris/MediaPlayer2", "org.freedesktop.DBus.Properties.Get",
+		"string:org.mpris.MediaPlayer2.Player", "string:"+property)
+}
+
+var (
+	dbusString = regexp.MustCompile(`^\s*string "(.*)"\s*$`)
+	dbusNumber = regexp.MustCompile(`(?:int64|uint64|double)\s+(-?[\d.]+)`)
+)
+
+func (mprisProvider) NowPlaying() (*NowPlaying, error) {
+	if _, err := exec.LookPath("dbus-send"); err != nil {
+		return nil, err
+	}
+	out, err := dbusSend("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus.ListNames")
+	if err != nil {
+		return nil, err
+	}
+	var found *NowPlaying
+	for line := range strings.Lines(out) {
+		m := dbusString.FindStringSubmatch(line)
+		if m == nil || !strings.HasPrefix(m[1], "org.mpris.MediaPlayer2.") {
+			continue
+		}
+		player := m[1]
+		metadata, err := mprisProperty(player, "Metadata")
+		if err != nil {
+			continue
+		}
+		fields := ParseMPRISMetadata(metadata)
+		np := &NowPlaying{
+			Source: strings.TrimPrefix(player, "org.mpris.MediaPlayer2."),
+			URL:    fields["xesam:url"],
+			Title:  fields["xesam:title"],
+			Artist: fields["xesam:artist"],
+			Album:  fields["xesam:album"],
+		}
+		if np.URL == "" && np.Title == "" {
+			continue
+		}
+		if status, err := mprisProperty(player, "PlaybackStatus"); err == nil {
+			np.Paused = !strings.Contains(status, `"Playing"`)
+		}
+		if position, err := mprisProperty(player, "Position"); err == nil {
+			if m := dbusNumber.FindStringSubmatch(position); m != nil {
+				us, _ := strconv.ParseFloat(m[1], 64)
+				np.Position = us / 1e6
+			}
+		}
+		if !np.Paused {
+			return np, nil
+		}
+		if found == nil {
+			found = np
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no MPRIS player")
+	}
+	return found, nil
+}
+
+// ParseMPRISMetadata reads the `a{sv}` printed by `dbus-send --print-reply`.
+// Lists such as `xesam:artist` are joined with ", ".
+func ParseMPRISMetadata(out string) map[string]string {
+	fields := make(map[string]string)
+	key := ""
+	values := make([]string, 0)
+	for line := range strings.Lines(out) {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "dict entry(":
+			key, values = "", values[:0]
+		case line == ")":
+			if key != "" && len(values) > 0 {
+				fields[key] = strings.Join(values, ", ")
+			}
+			key = ""
+		case key == "":
+			if m := dbusString.FindStringSubmatch(line); m != nil {
+				key = m[1]
+			}
+		default:
+			s := strings.TrimSpace(strings.TrimPrefix(line, "variant"))
+			if m := dbusString.FindStringSubmatch(s); m != nil {
+				values = append(values, m[1])
+			} else if m := dbusNumber.FindStringSubmatch(s); m != nil {
+				values = append(values, m[1])
+			}
+		}
+	}
+	return fields
+}
+
+// nowPlayingCLIProvider reads the macOS media session through `nowplaying-cli`
+type nowPlayingCLIProvider struct{}
+
+func (nowPlayingCLIProvider) Name() string {
+	return "nowplaying-cli"
+}
+
+func (nowPlayingCLIProvider) NowPlaying() (*NowPlaying, error) {
+	out, err := exec.Command("nowplaying-cli", "get", "title", "artist", "elapsedTime", "playbackRate").Output()
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	for len(lines) < 4 {
+		lines = append(lines, "null")
+	}
+	for i, l := range lines {
+		if l == "null" {
+			lines[i] = ""
+		}
+	}
+	if lines[0] == "" {
+		return nil, fmt.Errorf("nothing playing")
+	}
+	// podcast apps put the podcast in the artist
+	np := &NowPlaying{Source: "nowplaying-cli", Title: lines[0], Artist: lines[1], Album: lines[1]}
+	np.Position, _ = strconv.ParseFloat(lines[2], 64)
+	np.Paused = lines[3] == "0"
+	return np, nil
+}
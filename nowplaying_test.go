@@ -0,0 +1,61 @@
+package main_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/twio142/alfred-podcasts"
+)
+
+func TestParseMPRISMetadata(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		out  string
+		want map[string]string
+	}{
+		{
+			name: "mpv",
+			out: `method return time=1760000000.000000 sender=:1.42 -> destination=:1.99 serial=12 reply_serial=2
+   variant       array [
+         dict entry(
+            string "mpris:trackid"
+            variant                object path "/1"
+         )
+         dict entry(
+            string "xesam:title"
+            variant                string "Episode 12: "Quotes""
+         )
+         dict entry(
+            string "xesam:artist"
+            variant                array [
+                  string "Alice"
+                  string "Bob"
+               ]
+         )
+         dict entry(
+            string "xesam:url"
+            variant                string "https://example.com/ep12.mp3?t=90"
+         )
+         dict entry(
+            string "mpris:length"
+            variant                int64 3600000000
+         )
+      ]
+`,
+			want: map[string]string{
+				"xesam:title":  `Episode 12: "Quotes"`,
+				"xesam:artist": "Alice, Bob",
+				"xesam:url":    "https://example.com/ep12.mp3?t=90",
+				"mpris:length": "3600000000",
+			},
+		},
+		{name: "empty", out: "method return\n   variant       array [\n      ]\n", want: map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := main.ParseMPRISMetadata(tt.out); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMPRISMetadata() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,520 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Playlist is a smart playlist defined in the `playlists` workflow config,
+// one `Name: rule` per line, e.g.
+//
+//	Quick News: unplayed and duration < 30m and tag = news and date > 7d
+type Playlist struct {
+	Name string
+	Rule *Rule
+}
+
+// Rule is a compiled playlist rule. Rules combine conditions with `and`,
+// `or`, `not` and parentheses. A condition is a field, optionally compared to
+// a value:
+//
+//   - podcast, title, tag: `=`, `!=`, `~` (contains), `!~`
+//   - duration, playedUpTo, left: numbers in minutes, or `1h30m`, `90s`
+//   - date: `2024-05-01`, or `7d`, `2w`, `12h` ago; `date > 7d` is the last week
+//   - starred, played, unplayed, inProgress, archived, downloaded: booleans
+type Rule struct {
+	Source string
+	match  func(e *Episode) bool
+}
+
+func (r *Rule) Match(e *Episode) bool {
+	return r.match(e)
+}
+
+// GetPlaylists reads the config file first, then the `playlists` workflow config
+func GetPlaylists() ([]*Playlist, error) {
+	playlists := make([]*Playlist, 0, len(config.Playlists))
+	for name, source := range config.Playlists {
+		rule, err := ParseRule(source)
+		if err != nil {
+			return nil, fmt.Errorf("playlist %s: %v", name, err)
+		}
+		playlists = append(playlists, &Playlist{Name: name, Rule: rule})
+	}
+	sort.Slice(playlists, func(i, j int) bool { return playlists[i].Name < playlists[j].Name })
+	fromEnv, err := ParsePlaylists(os.Getenv("playlists"))
+	if err != nil {
+		return nil, err
+	}
+	return append(playlists, fromEnv...), nil
+}
+
+func ParsePlaylists(config string) ([]*Playlist, error) {
+	playlists := make([]*Playlist, 0)
+	for line := range strings.Lines(config) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, source, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid playlist definition: %s", line)
+		}
+		rule, err := ParseRule(source)
+		if err != nil {
+			return nil, fmt.Errorf("playlist %s: %v", strings.TrimSpace(name), err)
+		}
+		playlists = append(playlists, &Playlist{Name: strings.TrimSpace(name), Rule: rule})
+	}
+	return playlists, nil
+}
+
+func GetPlaylist(name string) (*Playlist, error) {
+	playlists, err := GetPlaylists()
+	if err != nil {
+		return nil, err
+	}
+	for _, pl := range playlists {
+		if strings.EqualFold(pl.Name, strings.TrimSpace(name)) {
+			return pl, nil
+		}
+	}
+	return nil, fmt.Errorf("playlist not found: %s", name)
+}
+
+// Episodes evaluates the rule over the cached episodes of all podcasts,
+// newest first
+func (pl *Playlist) Episodes() ([]*Episode, error) {
+	if err := GetAllPodcasts(false); err != nil {
+		return nil, err
+	}
+	episodes := make([]*Episode, 0)
+	for _, p := range podcastMap {
+		for _, e := range p.EpisodeMap {
+			if e.Podcast == "" {
+				e.Podcast = p.Name
+			}
+			if pl.Rule.Match(e) {
+				episodes = append(episodes, e)
+			}
+		}
+	}
+	sort.Slice(episodes, func(i, j int) bool {
+		return episodes[i].Date.After(episodes[j].Date)
+	})
+	return episodes, nil
+}
+
+// Export writes the playlist to an M3U file in the cache directory
+func (pl *Playlist) Export() (string, error) {
+	episodes, err := pl.Episodes()
+	if err != nil {
+		return "", err
+	}
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == ':' {
+			return '-'
+		}
+		return r
+	}, pl.Name)
+	return writePlaylist(episodes, getCachePath(name+".m3u"))
+}
+
+// podcastTags reads `[tags]` of the config file and the `podcastTags`
+// workflow config, one `tag: Podcast Name, Podcast Name` per line
+func podcastTags() map[string][]string {
+	tags := make(map[string][]string)
+	for tag, names := range config.Tags {
+		for _, name := range names {
+			name = strings.ToLower(strings.TrimSpace(name))
+			tags[name] = append(tags[name], strings.ToLower(tag))
+		}
+	}
+	for line := range strings.Lines(os.Getenv("podcastTags")) {
+		tag, names, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		for name := range strings.SplitSeq(names, ",") {
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				tags[name] = append(tags[name], tag)
+			}
+		}
+	}
+	return tags
+}
+
+// downloaded tells if the enclosure is found in `downloadDir`
+func (e *Episode) downloaded() bool {
+	dir := config.DownloadDir
+	if dir == "" {
+		dir = os.Getenv("downloadDir")
+	}
+	if dir == "" || e.URL == "" {
+		return false
+	}
+	if strings.HasPrefix(dir, "~/") {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, dir[2:])
+	}
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return false
+	}
+	name, _ := url.PathUnescape(path.Base(u.Path))
+	if name == "" || name == "/" || name == "." {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(dir, name))
+	return err == nil
+}
+
+type ruleToken struct {
+	kind  string // word, string, op, (, )
+	value string
+}
+
+func (t ruleToken) String() string {
+	if t.value == "" {
+		return t.kind
+	}
+	return t.value
+}
+
+func tokenizeRule(source string) ([]ruleToken, error) {
+	tokens := make([]ruleToken, 0)
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, ruleToken{kind: string(r)})
+			i++
+		case r == '"' || r == '\'':
+			j := i + 1
+			for j < len(runes) && runes[j] != r {
+				j++
+			}
+			if j == len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, ruleToken{kind: "string", value: string(runes[i+1 : j])})
+			i = j + 1
+		case strings.ContainsRune("=!<>~", r):
+			j := i + 1
+			for j < len(runes) && strings.ContainsRune("=~", runes[j]) && j-i < 2 {
+				j++
+			}
+			tokens = append(tokens, ruleToken{kind: "op", value: string(runes[i:j])})
+			i = j
+		default:
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune("()\"'=!<>~", runes[j]) {
+				j++
+			}
+			tokens = append(tokens, ruleToken{kind: "word", value: string(runes[i:j])})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type ruleParser struct {
+	tokens []ruleToken
+	pos    int
+	tags   map[string][]string
+}
+
+func ParseRule(source string) (*Rule, error) {
+	tokens, err := tokenizeRule(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty rule")
+	}
+	p := &ruleParser{tokens: tokens, tags: podcastTags()}
+	match, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return &Rule{Source: strings.TrimSpace(source), match: match}, nil
+}
+
+func (p *ruleParser) peek() *ruleToken {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *ruleParser) keyword(word string) bool {
+	if t := p.peek(); t != nil && t.kind == "word" && strings.EqualFold(t.value, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *ruleParser) parseOr() (func(*Episode) bool, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(e *Episode) bool { return l(e) || right(e) }
+	}
+	return left, nil
+}
+
+func (p *ruleParser) parseAnd() (func(*Episode) bool, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(e *Episode) bool { return l(e) && right(e) }
+	}
+	return left, nil
+}
+
+func (p *ruleParser) parseNot() (func(*Episode) bool, error) {
+	if p.keyword("not") {
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(e *Episode) bool { return !inner(e) }, nil
+	}
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("unexpected end of rule")
+	}
+	if t.kind == "(" {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.peek(); t == nil || t.kind != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return inner, nil
+	}
+	return p.parseCondition()
+}
+
+var boolFields = map[string]func(e *Episode) bool{
+	"starred":    func(e *Episode) bool { return e.Starred },
+	"played":     func(e *Episode) bool { return e.IsPlayed() },
+	"unplayed":   func(e *Episode) bool { return e.IsUnplayed() },
+	"inprogress": func(e *Episode) bool { return !e.IsPlayed() && e.PlayedUpTo > 0 },
+	"archived":   func(e *Episode) bool { return e.Archived },
+	"downloaded": func(e *Episode) bool { return e.downloaded() },
+}
+
+var numberFields = map[string]func(e *Episode) int{
+	"duration":   func(e *Episode) int { return e.Duration },
+	"playedupto": func(e *Episode) int { return e.PlayedUpTo },
+	"left":       func(e *Episode) int { return max(e.Duration-e.PlayedUpTo, 0) },
+}
+
+func (p *ruleParser) parseCondition() (func(*Episode) bool, error) {
+	t := p.peek()
+	if t.kind != "word" {
+		return nil, fmt.Errorf("expected a field, got %q", *t)
+	}
+	p.pos++
+	field := strings.ToLower(t.value)
+	var op, value string
+	if t := p.peek(); t != nil && t.kind == "op" {
+		op = t.value
+		p.pos++
+		v := p.peek()
+		if v == nil || (v.kind != "word" && v.kind != "string") {
+			return nil, fmt.Errorf("missing value for %s", field)
+		}
+		value = v.value
+		p.pos++
+	}
+
+	if get, ok := boolFields[field]; ok {
+		switch {
+		case op == "":
+			return get, nil
+		case op != "=" && op != "==" && op != "!=":
+			return nil, fmt.Errorf("invalid operator for %s: %s", field, op)
+		}
+		want, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %s", field, value)
+		}
+		if op == "!=" {
+			want = !want
+		}
+		return func(e *Episode) bool { return get(e) == want }, nil
+	}
+	if op == "" {
+		return nil, fmt.Errorf("missing comparison for %s", field)
+	}
+
+	switch field {
+	case "podcast", "title", "tag":
+		value = strings.ToLower(value)
+		var test func(s string) bool
+		switch op {
+		case "=", "==", "!=":
+			test = func(s string) bool { return s == value }
+		case "~", "!~":
+			test = func(s string) bool { return strings.Contains(s, value) }
+		default:
+			return nil, fmt.Errorf("invalid operator for %s: %s", field, op)
+		}
+		values := func(e *Episode) []string {
+			switch field {
+			case "podcast":
+				return []string{strings.ToLower(e.Podcast)}
+			case "title":
+				return []string{strings.ToLower(e.Title)}
+			default:
+				return p.tags[strings.ToLower(e.Podcast)]
+			}
+		}
+		negate := strings.HasPrefix(op, "!")
+		return func(e *Episode) bool {
+			for _, s := range values(e) {
+				if test(s) {
+					return !negate
+				}
+			}
+			return negate
+		}, nil
+	case "date":
+		date, err := parseRuleDate(value)
+		if err != nil {
+			return nil, err
+		}
+		cmp, err := compareFunc(op)
+		if err != nil {
+			return nil, err
+		}
+		return func(e *Episode) bool { return cmp(e.Date.Compare(date)) }, nil
+	}
+
+	get, ok := numberFields[field]
+	if !ok {
+		return nil, fmt.Errorf("unknown field: %s", field)
+	}
+	seconds, err := parseRuleDuration(value)
+	if err != nil {
+		return nil, err
+	}
+	cmp, err := compareFunc(op)
+	if err != nil {
+		return nil, err
+	}
+	return func(e *Episode) bool {
+		n := get(e)
+		switch {
+		case n < seconds:
+			return cmp(-1)
+		case n > seconds:
+			return cmp(1)
+		}
+		return cmp(0)
+	}, nil
+}
+
+func compareFunc(op string) (func(int) bool, error) {
+	switch op {
+	case "=", "==":
+		return func(c int) bool { return c == 0 }, nil
+	case "!=":
+		return func(c int) bool { return c != 0 }, nil
+	case "<":
+		return func(c int) bool { return c < 0 }, nil
+	case "<=":
+		return func(c int) bool { return c <= 0 }, nil
+	case ">":
+		return func(c int) bool { return c > 0 }, nil
+	case ">=":
+		return func(c int) bool { return c >= 0 }, nil
+	}
+	return nil, fmt.Errorf("invalid operator: %s", op)
+}
+
+var ruleDurationRegexp = regexp.MustCompile(`^(?:(\d+)h)?(?:(\d+)m)?(?:(\d+)s)?$`)
+
+// parseRuleDuration reads minutes, or a duration like `1h30m`, as seconds
+func parseRuleDuration(s string) (int, error) {
+	s = strings.ToLower(s)
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return int(n * 60), nil
+	}
+	m := ruleDurationRegexp.FindStringSubmatch(s)
+	if m == nil || s == "" {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+	return atoi(m[1])*3600 + atoi(m[2])*60 + atoi(m[3]), nil
+}
+
+var ruleAgeRegexp = regexp.MustCompile(`^(\d+)([hdwmy])$`)
+
+// parseRuleDate reads a date, or an age such as `7d` counted back from now
+func parseRuleDate(s string) (time.Time, error) {
+	s = strings.ToLower(s)
+	now := time.Now()
+	switch s {
+	case "today":
+		y, m, d := now.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, now.Location()), nil
+	case "yesterday":
+		y, m, d := now.AddDate(0, 0, -1).Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, now.Location()), nil
+	}
+	if m := ruleAgeRegexp.FindStringSubmatch(s); m != nil {
+		n := atoi(m[1])
+		switch m[2] {
+		case "h":
+			return now.Add(-time.Duration(n) * time.Hour), nil
+		case "d":
+			return now.AddDate(0, 0, -n), nil
+		case "w":
+			return now.AddDate(0, 0, -7*n), nil
+		case "m":
+			return now.AddDate(0, -n, 0), nil
+		default:
+			return now.AddDate(-n, 0, 0), nil
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date: %s", s)
+}
@@ -0,0 +1,83 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/twio142/alfred-podcasts"
+)
+
+func TestParseRule(t *testing.T) {
+	t.Setenv("podcastTags", "news: The Daily, Up First")
+	daily := &main.Episode{
+		Title:    "Morning Briefing",
+		Podcast:  "The Daily",
+		Date:     time.Now().AddDate(0, 0, -2),
+		Duration: 20 * 60,
+		Status:   1,
+	}
+	old := &main.Episode{
+		Title:      "A Long Interview",
+		Podcast:    "Conversations",
+		Date:       time.Now().AddDate(0, -2, 0),
+		Duration:   2 * 3600,
+		PlayedUpTo: 600,
+		Starred:    true,
+	}
+	tests := []struct {
+		name    string // description of this test case
+		rule    string
+		want    []bool // matches for daily, old
+		wantErr bool
+	}{
+		{
+			name: "combined rule",
+			rule: "unplayed and duration < 30m and tag = news and date > 7d",
+			want: []bool{true, false},
+		},
+		{
+			name: "or, not and parentheses",
+			rule: `not (podcast = "the daily" or title ~ briefing) and starred`,
+			want: []bool{false, true},
+		},
+		{
+			name: "durations",
+			rule: "duration >= 1h30m and left > 100",
+			want: []bool{false, true},
+		},
+		{
+			name: "absolute date",
+			rule: "date < 2000-01-01 or inProgress",
+			want: []bool{false, true},
+		},
+		{
+			name:    "unknown field",
+			rule:    "rating > 3",
+			wantErr: true,
+		},
+		{
+			name:    "missing parenthesis",
+			rule:    "(starred or played",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, gotErr := main.ParseRule(tt.rule)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("ParseRule() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("ParseRule() succeeded unexpectedly")
+			}
+			for i, e := range []*main.Episode{daily, old} {
+				if got := rule.Match(e); got != tt.want[i] {
+					t.Errorf("Match(%s) = %v, want %v", e.Title, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
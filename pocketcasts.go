@@ -12,8 +12,9 @@ import (
 )
 
 var (
-	tokenMutex       sync.Mutex
-	pocketCastsToken string
+	tokenMutex           sync.Mutex
+	pocketCastsToken     string
+	upNextServerModified int64
 )
 
 func getToken() error {
@@ -44,6 +45,7 @@ type PocketCastsUpNextResponse struct {
 		PlayedUpTo int    `json:"playedUpTo"`
 		Duration   int    `json:"duration"`
 	}
+	ServerModified int64 `json:"serverModified"`
 }
 
 type PocketCastsPodcastsResponse struct {
@@ -65,13 +67,15 @@ type PocketCastsEpisodesResponse struct {
 		Link     string `json:"url"`
 		Desc     string `json:"description"`
 		Episodes []struct {
-			UUID      string    `json:"uuid"`
-			Title     string    `json:"title"`
-			URL       string    `json:"url"`
-			ShowNotes string    `json:"show_notes"`
-			Image     string    `json:"image"`
-			Date      time.Time `json:"published"`
-			Duration  int       `json:"duration"`
+			UUID        string            `json:"uuid"`
+			Title       string            `json:"title"`
+			URL         string            `json:"url"`
+			ShowNotes   string            `json:"show_notes"`
+			Image       string            `json:"image"`
+			Date        time.Time         `json:"published"`
+			Duration    int               `json:"duration"`
+			ChaptersURL string            `json:"chapters_url"`
+			Transcripts []*TranscriptInfo `json:"transcripts"`
 		}
 	} `json:"podcast"`
 }
@@ -89,6 +93,30 @@ type PocketCastsNewReleasesResponse struct {
 	}
 }
 
+type PocketCastsEpisodeSyncResponse struct {
+	Episodes []struct {
+		UUID       string `json:"uuid"`
+		Status     int    `json:"playingStatus"`
+		PlayedUpTo int    `json:"playedUpTo"`
+		Duration   int    `json:"duration"`
+		Archived   bool   `json:"isDeleted"`
+		Starred    bool   `json:"starred"`
+	} `json:"episodes"`
+}
+
+type PocketCastsFiltersResponse struct {
+	Filters []*Filter `json:"playlists"`
+}
+
+type PocketCastsStatsResponse struct {
+	Listened       flexInt   `json:"timeListened"`
+	Skipping       flexInt   `json:"timeSkipping"`
+	IntroSkipping  flexInt   `json:"timeIntroSkipping"`
+	VariableSpeed  flexInt   `json:"timeVariableSpeed"`
+	SilenceRemoval flexInt   `json:"timeSilenceRemoval"`
+	StartedAt      time.Time `json:"timesStartedAt"`
+}
+
 func PocketCastsRequest(endpoint string, body *map[string]any, response any) error {
 	URL := "https://"
 	method := "POST"
@@ -171,7 +199,7 @@ func GetPodcastList(force bool) error {
 	if !force && len(podcastMap) > 0 {
 		return nil
 	}
-	maxAge := 24 * time.Hour
+	maxAge := time.Duration(config.Cache.PodcastList)
 	if force {
 		maxAge = 0
 	}
@@ -199,7 +227,7 @@ func GetPodcastList(force bool) error {
 			Author:      p.Author,
 			Desc:        p.Desc,
 			LastUpdated: p.LastUpdated,
-			Image:       fmt.Sprintf("https://static.pocketcasts.com/discover/images/webp/200/%s.webp", p.UUID),
+			Image:       podcastImageURL(p.UUID),
 		}
 		podcastMap[p.UUID] = _p
 	}
@@ -210,7 +238,7 @@ func GetPodcastList(force bool) error {
 
 func GetUpNext(force bool) ([]*Episode, error) {
 	episodes := make([]*Episode, 0)
-	maxAge := 30 * time.Minute
+	maxAge := time.Duration(config.Cache.UpNext)
 	if force {
 		maxAge = 0
 	}
@@ -243,6 +271,9 @@ func GetUpNext(force bool) ([]*Episode, error) {
 
 func processUpNextResponse(response *PocketCastsUpNextResponse) ([]*Episode, error) {
 	upNextMap = make(map[string]*Episode)
+	if response.ServerModified > 0 {
+		upNextServerModified = response.ServerModified
+	}
 	if len(podcastMap) == 0 {
 		_ = GetPodcastList(false)
 	}
@@ -266,7 +297,7 @@ func processUpNextResponse(response *PocketCastsUpNextResponse) ([]*Episode, err
 			Podcast:     p.Name,
 			PodcastUUID: p.UUID,
 			Date:        e.Date,
-			Image:       fmt.Sprintf("https://static.pocketcasts.com/discover/images/webp/200/%s.webp", e.PodcastUUID),
+			Image:       podcastImageURL(e.PodcastUUID),
 		}
 		upNextMap[e.UUID] = _e
 		episodes[i] = _e
@@ -295,7 +326,7 @@ func GetList(list string, force bool) ([]*Episode, error) {
 		return nil, fmt.Errorf("invalid list: %s", list)
 	}
 	episodes := make([]*Episode, 0)
-	maxAge := 12 * time.Hour
+	maxAge := time.Duration(config.Cache.Lists)
 	if force {
 		maxAge = 0
 	}
@@ -333,7 +364,9 @@ func GetList(list string, force bool) ([]*Episode, error) {
 			Podcast:     p.Name,
 			PodcastUUID: p.UUID,
 			Date:        e.Date,
-			Image:       fmt.Sprintf("https://static.pocketcasts.com/discover/images/webp/200/%s.webp", e.PodcastUUID),
+			Duration:    e.Duration,
+			PlayedUpTo:  e.PlayedUpTo,
+			Image:       podcastImageURL(e.PodcastUUID),
 		}
 		episodes = append(episodes, _e)
 		if p.EpisodeMap == nil {
@@ -365,7 +398,7 @@ func (p *Podcast) GetInfo() error {
 	p.Author = response.Podcast.Author
 	p.Desc = response.Podcast.Desc
 	p.Link = response.Podcast.Link
-	p.Image = fmt.Sprintf("https://static.pocketcasts.com/discover/images/webp/200/%s.webp", p.UUID)
+	p.Image = podcastImageURL(p.UUID)
 	data, _ := json.Marshal(p)
 	_ = writeCache(file, data)
 	return nil
@@ -375,7 +408,7 @@ func (p *Podcast) GetEpisodes(force bool) error {
 	if err := p.resolveMetadata(); err != nil {
 		return err
 	}
-	maxAge := 12 * time.Hour
+	maxAge := time.Duration(config.Cache.Episodes)
 	if force {
 		maxAge = 0
 	}
@@ -388,6 +421,43 @@ func (p *Podcast) GetEpisodes(force bool) error {
 	return p.fetchAndUpdateEpisodes()
 }
 
+// saveEpisodeStates writes the progress and archive state of `episodes` into
+// their podcasts' cache, which keeps its age
+func saveEpisodeStates(episodes []*Episode) {
+	byPodcast := make(map[string][]*Episode)
+	for _, e := range episodes {
+		byPodcast[e.PodcastUUID] = append(byPodcast[e.PodcastUUID], e)
+	}
+	for uuid, list := range byPodcast {
+		file := getCachePath("podcasts", uuid)
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var p Podcast
+		if err := json.Unmarshal(data, &p); err != nil {
+			continue
+		}
+		for _, e := range list {
+			if _e, ok := p.EpisodeMap[e.UUID]; ok {
+				_e.Status = e.Status
+				_e.PlayedUpTo = e.PlayedUpTo
+				_e.Archived = e.Archived
+			}
+		}
+		if data, err = json.Marshal(&p); err != nil {
+			continue
+		}
+		if err := writeCache(file, data); err == nil {
+			_ = os.Chtimes(file, info.ModTime(), info.ModTime())
+		}
+	}
+}
+
 func (p *Podcast) resolveMetadata() error {
 	if p.UUID == "" {
 		if p.Name != "" {
@@ -427,8 +497,16 @@ func (p *Podcast) fetchAndUpdateEpisodes() error {
 		ch2 <- requestResult{&response, err}
 	}()
 
+	ch3 := make(chan error)
+	var syncResponse PocketCastsEpisodeSyncResponse
+	go func() {
+		body := map[string]any{"uuid": p.UUID}
+		ch3 <- PocketCastsRequest("/user/podcast/episodes", &body, &syncResponse)
+	}()
+
 	result1 := <-ch1
 	result2 := <-ch2
+	syncErr := <-ch3
 
 	if result1.err != nil {
 		return result1.err
@@ -441,7 +519,7 @@ func (p *Podcast) fetchAndUpdateEpisodes() error {
 	p.Author = result1.response.Podcast.Author
 	p.Desc = result1.response.Podcast.Desc
 	p.Link = result1.response.Podcast.Link
-	p.Image = fmt.Sprintf("https://static.pocketcasts.com/discover/images/webp/200/%s.webp", p.UUID)
+	p.Image = podcastImageURL(p.UUID)
 	p.EpisodeMap = make(map[string]*Episode)
 
 	for _, e := range result1.response.Podcast.Episodes {
@@ -464,46 +542,56 @@ func (p *Podcast) fetchAndUpdateEpisodes() error {
 	for _, e := range result2.response.Podcast.Episodes {
 		if _e, ok := p.EpisodeMap[e.UUID]; ok {
 			_e.ShowNotes = e.ShowNotes
+			_e.ChaptersURL = e.ChaptersURL
+			_e.Transcripts = e.Transcripts
 			if e.Image != "" {
 				_e.Image = e.Image
 			}
 		}
 	}
 
+	// the podcast may not be subscribed, in which case there is no sync state
+	if syncErr != nil {
+		fmt.Fprintf(os.Stderr, "[%s]: sync state: %s\n", p.Name, syncErr)
+	} else {
+		p.applySyncState(&syncResponse)
+	}
+
 	data, _ := json.Marshal(p)
 	file := getCachePath("podcasts", p.UUID)
 	_ = writeCache(file, data)
 	return nil
 }
 
-func resolveEpisodeURL(shareURL string) (podcastUUID, episodeUUID string, err error) {
-	if strings.Contains(shareURL, "pocketcasts.com/podcast/") {
-		return parseEpisodePath(shareURL)
-	}
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-	}
-	resp, err := client.Get(shareURL)
-	if err != nil {
-		return "", "", fmt.Errorf("error resolving URL: %v", err)
-	}
-	defer func() { _ = resp.Body.Close() }()
-	location := resp.Header.Get("Location")
-	if location == "" {
-		return "", "", fmt.Errorf("no redirect found for URL: %s", shareURL)
+func (p *Podcast) applySyncState(response *PocketCastsEpisodeSyncResponse) {
+	for _, e := range response.Episodes {
+		if _e, ok := p.EpisodeMap[e.UUID]; ok {
+			_e.Status = e.Status
+			_e.PlayedUpTo = e.PlayedUpTo
+			_e.Archived = e.Archived
+			_e.Starred = e.Starred
+			if _e.Duration == 0 {
+				_e.Duration = e.Duration
+			}
+		}
 	}
-	return parseEpisodePath(location)
 }
 
+// GetEpisodeByURL resolves a link to an episode, see `ResolveURL`
 func GetEpisodeByURL(shareURL string) (*Episode, error) {
-	podcastUUID, episodeUUID, err := resolveEpisodeURL(shareURL)
+	r, err := ResolveURL(shareURL)
 	if err != nil {
 		return nil, err
 	}
+	if r.Episode == nil {
+		return nil, fmt.Errorf("not an episode link: %s", shareURL)
+	}
+	return r.Episode, nil
+}
 
+// fetchEpisode gets an episode with its show notes, whether or not the
+// podcast is subscribed
+func fetchEpisode(podcastUUID, episodeUUID string) (*Episode, error) {
 	type requestResult struct {
 		response *PocketCastsEpisodesResponse
 		err      error
@@ -546,7 +634,7 @@ func GetEpisodeByURL(shareURL string) (*Episode, error) {
 				PodcastUUID: podcastUUID,
 				Date:        ep.Date,
 				Duration:    ep.Duration,
-				Image:       fmt.Sprintf("https://static.pocketcasts.com/discover/images/webp/200/%s.webp", podcastUUID),
+				Image:       podcastImageURL(podcastUUID),
 			}
 			break
 		}
@@ -558,6 +646,8 @@ func GetEpisodeByURL(shareURL string) (*Episode, error) {
 	for _, ep := range r2.response.Podcast.Episodes {
 		if ep.UUID == episodeUUID {
 			episode.ShowNotes = ep.ShowNotes
+			episode.ChaptersURL = ep.ChaptersURL
+			episode.Transcripts = ep.Transcripts
 			if ep.Image != "" {
 				episode.Image = ep.Image
 			}
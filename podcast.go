@@ -1,13 +1,14 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
 	"os"
 	"os/exec"
-	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
@@ -19,7 +20,8 @@ import (
 type Podcast struct {
 	Name        string              `json:"name"`
 	Author      string              `json:"author"`
-	URL         string              `json:"-"`
+	URL         string              `json:"feed,omitempty"`
+	ITunesID    int                 `json:"itunesId,omitempty"`
 	Desc        string              `json:"desc"`
 	Image       string              `json:"image"`
 	Link        string              `json:"link"`
@@ -29,17 +31,87 @@ type Podcast struct {
 }
 
 type Episode struct {
-	Title       string    `json:"title"`
-	URL         string    `json:"url"`
-	ShowNotes   string    `json:"show_notes,omitempty"`
-	Podcast     string    `json:"podcast"`
-	PodcastUUID string    `json:"podcast_uuid"`
-	Date        time.Time `json:"date"`
-	Duration    int       `json:"duration"`
-	PlayedUpTo  int       `json:"playedUpTo,omitempty"`
-	Played      bool      `json:"-"`
-	Image       string    `json:"image,omitempty"`
-	UUID        string    `json:"uuid"`
+	Title       string            `json:"title"`
+	URL         string            `json:"url"`
+	ShowNotes   string            `json:"show_notes,omitempty"`
+	Podcast     string            `json:"podcast"`
+	PodcastUUID string            `json:"podcast_uuid"`
+	Date        time.Time         `json:"date"`
+	Duration    int               `json:"duration"`
+	PlayedUpTo  int               `json:"playedUpTo,omitempty"`
+	Status      int               `json:"playingStatus,omitempty"`
+	Archived    bool              `json:"archived,omitempty"`
+	Starred     bool              `json:"starred,omitempty"`
+	Played      bool              `json:"-"`
+	Image       string            `json:"image,omitempty"`
+	ChaptersURL string            `json:"chaptersUrl,omitempty"`
+	Transcripts []*TranscriptInfo `json:"transcripts,omitempty"`
+	UUID        string            `json:"uuid"`
+}
+
+// playing status used by the Pocket Casts sync API
+const (
+	statusUnplayed   = 1
+	statusInProgress = 2
+	statusPlayed     = 3
+)
+
+var episodeSortModes = []string{"newest", "oldest", "longest", "unplayed"}
+
+// parseEpisodeQuery splits a sort mode prefix such as `old:` or `u:` off the query
+func parseEpisodeQuery(query string) (mode, term string) {
+	query = strings.TrimSpace(query)
+	if key, rest, ok := strings.Cut(query, ":"); ok && key != "" && !strings.Contains(key, " ") {
+		for _, m := range episodeSortModes {
+			if strings.HasPrefix(m, strings.ToLower(key)) {
+				return m, strings.TrimSpace(rest)
+			}
+		}
+	}
+	return episodeSortModes[0], query
+}
+
+func (p *Podcast) FilterEpisodes(term string, mode string) []*Episode {
+	words := strings.Fields(strings.ToLower(term))
+	episodes := make([]*Episode, 0, len(p.EpisodeMap))
+	for _, e := range p.EpisodeMap {
+		match := strings.ToLower(matchString(e.Title))
+		ok := true
+		for _, w := range words {
+			if !strings.Contains(match, w) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			episodes = append(episodes, e)
+		}
+	}
+	sort.SliceStable(episodes, func(i, j int) bool {
+		a, b := episodes[i], episodes[j]
+		switch mode {
+		case "oldest":
+			return a.Date.Before(b.Date)
+		case "longest":
+			if a.Duration != b.Duration {
+				return a.Duration > b.Duration
+			}
+		case "unplayed":
+			if a.IsUnplayed() != b.IsUnplayed() {
+				return a.IsUnplayed()
+			}
+		}
+		return a.Date.After(b.Date)
+	})
+	return episodes
+}
+
+func (e *Episode) IsPlayed() bool {
+	return e.Played || e.Status == statusPlayed
+}
+
+func (e *Episode) IsUnplayed() bool {
+	return !e.IsPlayed() && e.PlayedUpTo == 0
 }
 
 func (e *Episode) JSON() (string, error) {
@@ -68,10 +140,15 @@ func GetAllPodcasts(force bool) error {
 			if err := p.GetEpisodes(force); err != nil {
 				fmt.Fprintf(os.Stderr, "[%s]: %s\n", p.Name, err)
 			}
-			p.CacheArtwork()
 		}(p)
 	}
 	wg.Wait()
+	for _, p := range podcastMap {
+		if p.Image != "" && needsArtwork(getCachePath("artworks", p.UUID)) {
+			refreshInBackground([]string{"artworks"})
+			break
+		}
+	}
 	return nil
 }
 
@@ -114,13 +191,6 @@ func FindEpisode(args map[string]string) *Episode {
 	return nil
 }
 
-func (p *Podcast) CacheArtwork() {
-	file := getCachePath("artworks", p.UUID)
-	if _, err := os.Stat(file); os.IsNotExist(err) && p.Image != "" {
-		downloadImage(p.Image, file)
-	}
-}
-
 func (p *Podcast) ClearCache() {
 	if p.UUID == "" {
 		return
@@ -135,44 +205,57 @@ func (p *Podcast) ClearCache() {
 
 func (e *Episode) CacheShownotes() string {
 	file := getCachePath("shownotes", fmt.Sprintf("%s.%s.md", e.PodcastUUID, e.UUID))
-	if _, err := os.Stat(file); err == nil {
-		return file
+	header := shownotesHeader(e.ShowNotes)
+	if f, err := os.Open(file); err == nil {
+		// regenerate when the renderer or the show notes have changed
+		line, _ := bufio.NewReader(f).ReadString('\n')
+		_ = f.Close()
+		if strings.TrimSpace(line) == header || e.ShowNotes == "" {
+			return file
+		}
 	}
 	if e.ShowNotes == "" {
 		return ""
 	}
-	re := regexp.MustCompile(`(<(p|span) [^>]*style="[^"]*)background-color:.+?; ?`)
-	var showNotes string
-	showNotes = re.ReplaceAllString(e.ShowNotes, "$1")
-	re = regexp.MustCompile(`(<(p|span) [^>]*style=("[^"]+[^-]|"))color:.+?; ?`)
-	showNotes = re.ReplaceAllString(showNotes, "$1")
-	re = regexp.MustCompile(`<audio[^>]*(>[\s\S]*?</audio|/)>`)
-	showNotes = re.ReplaceAllString(showNotes, "")
-	showNotes = strings.ReplaceAll(showNotes, "\n", "<br/>")
+	showNotes, err := RenderShownotes(e.ShowNotes, e.PodcastUUID+"/"+e.UUID)
+	if err != nil {
+		return ""
+	}
 	if e.Image != "" {
 		showNotes += "\n\n<img width=\"20%\" src=\"" + e.Image + "\"/>"
 	}
-	_ = os.WriteFile(file, []byte(showNotes), 0o644)
+	_ = os.WriteFile(file, []byte(header+"\n\n"+showNotes+"\n"), 0o644)
 	return file
 }
 
 func GetPlaying() {
-	title := os.Getenv("title")
-	author := os.Getenv("author")
-	podcast := os.Getenv("podcast")
-	if title == "" {
-		cmd := exec.Command("nowplaying-cli", "get", "title", "artist", "album")
-		if out, err := cmd.Output(); err == nil {
-			output := strings.Split(string(out), "\n")
-			title = output[0]
-			author = output[1]
-			podcast = output[1]
-		}
+	var e *Episode
+	if title := os.Getenv("title"); title != "" {
+		e = FindEpisode(map[string]string{"title": title, "podcast": os.Getenv("podcast"), "author": os.Getenv("author")})
+	} else {
+		e, _, _ = GetNowPlaying()
 	}
-	if e := FindEpisode(map[string]string{"title": title, "podcast": podcast, "author": author}); e != nil {
+	if e != nil {
 		item := e.Format(true)
-		item.Mods.Cmd = nil
-		item.Mods.Alt = nil
+		item.Text.Copy = e.ShareURL(e.sharePosition())
+		// ⌘ list chapters
+		cmd := &Mod{Subtitle: "Chapters"}
+		cmd.SetVar("trigger", "chapters")
+		cmd.SetVar("uuid", e.UUID)
+		cmd.SetVar("podcastUuid", e.PodcastUUID)
+		item.Mods.Cmd = cmd
+		// ⌥ search transcript
+		alt := &Mod{Subtitle: "Search transcript"}
+		alt.SetVar("trigger", "transcript_search")
+		alt.SetVar("uuid", e.UUID)
+		alt.SetVar("podcastUuid", e.PodcastUUID)
+		item.Mods.Alt = alt
+		// ⇧⌘ list links in show notes
+		cmdShift := &Mod{Subtitle: "Links in show notes"}
+		cmdShift.SetVar("trigger", "links")
+		cmdShift.SetVar("uuid", e.UUID)
+		cmdShift.SetVar("podcastUuid", e.PodcastUUID)
+		item.Mods.CmdShift = cmdShift
 		workflow.AddItem(item)
 	} else {
 		workflow.WarnEmpty("No Episode Playing")
@@ -184,9 +267,13 @@ func ExportPlaylist() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	return writePlaylist(episodes, getCachePath("podcast_playlist.m3u"))
+}
+
+func writePlaylist(episodes []*Episode, file string) (string, error) {
 	list := make([]string, 0, len(episodes)*2)
 	for _, e := range episodes {
-		list = append(list, fmt.Sprintf("# %s\t%s", e.Podcast, e.Title))
+		list = append(list, fmt.Sprintf("# %s\t%s\t%s/%s", e.Podcast, e.Title, e.PodcastUUID, e.UUID))
 		u := e.URL
 		if e.PlayedUpTo > 0 {
 			// NOTE: add timestamp to URL
@@ -202,8 +289,6 @@ func ExportPlaylist() (string, error) {
 		}
 		list = append(list, u)
 	}
-	file := "podcast_playlist.m3u"
-	file = getCachePath(file)
 	if err := writeCache(file, []byte(strings.Join(list, "\n"))); err != nil {
 		return "", err
 	}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultPodcastIndexURL = "https://api.podcastindex.org/api/1.0"
+
+// PodcastIndex is a client of the Podcast Index API, which lists many
+// independent and non-English podcasts missing from Pocket Casts' search
+type PodcastIndex struct {
+	BaseURL string
+	Key     string
+	Secret  string
+	client  *http.Client
+}
+
+func NewPodcastIndex(baseURL, key, secret string) *PodcastIndex {
+	return &PodcastIndex{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Key:     key,
+		Secret:  secret,
+		client:  &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+type podcastIndexFeed struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Link        string `json:"link"`
+	Description string `json:"description"`
+	Author      string `json:"author"`
+	Image       string `json:"image"`
+	Artwork     string `json:"artwork"`
+	ITunesID    int    `json:"itunesId"`
+}
+
+type podcastIndexItem struct {
+	ID            int    `json:"id"`
+	Title         string `json:"title"`
+	Link          string `json:"link"`
+	Description   string `json:"description"`
+	DatePublished int64  `json:"datePublished"`
+	EnclosureURL  string `json:"enclosureUrl"`
+	Duration      int    `json:"duration"`
+	Image         string `json:"image"`
+	FeedImage     string `json:"feedImage"`
+	FeedID        int    `json:"feedId"`
+	FeedTitle     string `json:"feedTitle"`
+	FeedURL       string `json:"feedUrl"`
+	FeedAuthor    string `json:"feedAuthor"`
+	FeedITunesID  int    `json:"feedItunesId"`
+}
+
+func (f *podcastIndexFeed) podcast() *Podcast {
+	p := &Podcast{
+		Name:     f.Title,
+		Author:   f.Author,
+		Desc:     f.Description,
+		Image:    f.Artwork,
+		Link:     f.Link,
+		URL:      f.URL,
+		ITunesID: f.ITunesID,
+	}
+	if p.Image == "" {
+		p.Image = f.Image
+	}
+	return p
+}
+
+// request signs the call with the key, the time and the SHA-1 of both plus
+// the secret, as the API requires
+func (pi *PodcastIndex) request(endpoint string, params url.Values, response any) error {
+	if pi.Key == "" || pi.Secret == "" {
+		return fmt.Errorf("podcast index key and secret not set")
+	}
+	req, err := http.NewRequest("GET", pi.BaseURL+endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	hash := sha1.Sum([]byte(pi.Key + pi.Secret + now))
+	req.Header.Set("User-Agent", "alfred-podcasts")
+	req.Header.Set("X-Auth-Key", pi.Key)
+	req.Header.Set("X-Auth-Date", now)
+	req.Header.Set("Authorization", hex.EncodeToString(hash[:]))
+	resp, err := pi.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("podcast index request failed with status: %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("error decoding response: %v", err)
+	}
+	return nil
+}
+
+func (pi *PodcastIndex) feeds(endpoint string, params url.Values) ([]*Podcast, error) {
+	var response struct {
+		Feeds []*podcastIndexFeed `json:"feeds"`
+	}
+	if err := pi.request(endpoint, params, &response); err != nil {
+		return nil, err
+	}
+	podcasts := make([]*Podcast, len(response.Feeds))
+	for i, f := range response.Feeds {
+		podcasts[i] = f.podcast()
+	}
+	return podcasts, nil
+}
+
+func (pi *PodcastIndex) SearchByTerm(term string) ([]*Podcast, error) {
+	return pi.feeds("/search/byterm", url.Values{"q": {term}})
+}
+
+// SearchByPerson returns the podcasts with episodes featuring `name`, as host
+// or guest
+func (pi *PodcastIndex) SearchByPerson(name string) ([]*Podcast, error) {
+	var response struct {
+		Items []*podcastIndexItem `json:"items"`
+	}
+	if err := pi.request("/search/byperson", url.Values{"q": {name}}, &response); err != nil {
+		return nil, err
+	}
+	podcasts := make([]*Podcast, 0)
+	seen := make(map[int]bool)
+	for _, item := range response.Items {
+		if seen[item.FeedID] {
+			continue
+		}
+		seen[item.FeedID] = true
+		podcasts = append(podcasts, &Podcast{
+			Name:     item.FeedTitle,
+			Author:   item.FeedAuthor,
+			Image:    item.FeedImage,
+			URL:      item.FeedURL,
+			ITunesID: item.FeedITunesID,
+		})
+	}
+	return podcasts, nil
+}
+
+func (pi *PodcastIndex) Trending(limit int) ([]*Podcast, error) {
+	params := url.Values{"max": {strconv.Itoa(limit)}}
+	return pi.feeds("/podcasts/trending", params)
+}
+
+// EpisodesByFeed returns the latest episodes of a feed. They have no Pocket
+// Casts UUID until the feed is added there.
+func (pi *PodcastIndex) EpisodesByFeed(feed string, limit int) ([]*Episode, error) {
+	var response struct {
+		Items []*podcastIndexItem `json:"items"`
+	}
+	params := url.Values{"url": {feed}, "max": {strconv.Itoa(limit)}}
+	if err := pi.request("/episodes/byfeedurl", params, &response); err != nil {
+		return nil, err
+	}
+	episodes := make([]*Episode, len(response.Items))
+	for i, item := range response.Items {
+		episodes[i] = &Episode{
+			Title:     item.Title,
+			URL:       item.EnclosureURL,
+			ShowNotes: item.Description,
+			Podcast:   item.FeedTitle,
+			Date:      time.Unix(item.DatePublished, 0),
+			Duration:  item.Duration,
+			Image:     item.Image,
+		}
+	}
+	return episodes, nil
+}
+
+// podcastIndex is the configured client, or nil without key and secret
+func podcastIndex() *PodcastIndex {
+	if config.PodcastIndex.Key == "" || config.PodcastIndex.Secret == "" {
+		return nil
+	}
+	return NewPodcastIndex(config.PodcastIndex.BaseURL, config.PodcastIndex.Key, config.PodcastIndex.Secret)
+}
+
+type podcastIndexProvider struct {
+	*PodcastIndex
+}
+
+func (podcastIndexProvider) Name() string {
+	return "Podcast Index"
+}
+
+func (p podcastIndexProvider) SearchPodcasts(term string) ([]*Podcast, error) {
+	return p.SearchByTerm(term)
+}
+
+func (p podcastIndexProvider) SearchPerson(name string) ([]*Podcast, error) {
+	return p.SearchByPerson(name)
+}
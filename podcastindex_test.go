@@ -0,0 +1,82 @@
+package main_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/twio142/alfred-podcasts"
+)
+
+// podcastIndexStandIn answers like the Podcast Index API, checking the
+// signature of every request
+func podcastIndexStandIn(t *testing.T, key, secret string) *httptest.Server {
+	t.Helper()
+	responses := map[string]string{
+		"/search/byterm":      `{"status": "true", "feeds": [{"id": 1, "title": "Indie Show", "url": "https://example.com/feed.xml", "author": "Jane", "artwork": "https://example.com/a.jpg", "itunesId": 123}]}`,
+		"/search/byperson":    `{"status": "true", "items": [{"id": 10, "title": "Ep 1", "feedId": 1, "feedTitle": "Indie Show", "feedUrl": "https://example.com/feed.xml"}, {"id": 11, "title": "Ep 2", "feedId": 1, "feedTitle": "Indie Show", "feedUrl": "https://example.com/feed.xml"}, {"id": 12, "title": "Guest spot", "feedId": 2, "feedTitle": "Other Show", "feedUrl": "https://other.example/rss"}]}`,
+		"/podcasts/trending":  `{"status": "true", "feeds": [{"id": 2, "title": "Other Show", "url": "https://other.example/rss"}]}`,
+		"/episodes/byfeedurl": `{"status": "true", "items": [{"id": 10, "title": "Ep 1", "enclosureUrl": "https://example.com/1.mp3", "datePublished": 1700000000, "duration": 1800}]}`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hash := sha1.Sum([]byte(key + secret + r.Header.Get("X-Auth-Date")))
+		if r.Header.Get("X-Auth-Key") != key || r.Header.Get("Authorization") != hex.EncodeToString(hash[:]) || r.Header.Get("User-Agent") == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func TestPodcastIndex(t *testing.T) {
+	server := podcastIndexStandIn(t, "key", "secret")
+	defer server.Close()
+	pi := main.NewPodcastIndex(server.URL+"/", "key", "secret")
+
+	podcasts, err := pi.SearchByTerm("indie")
+	if err != nil {
+		t.Fatalf("SearchByTerm() error = %v", err)
+	}
+	if len(podcasts) != 1 || podcasts[0].URL != "https://example.com/feed.xml" || podcasts[0].ITunesID != 123 || podcasts[0].UUID != "" {
+		t.Errorf("SearchByTerm() = %+v", podcasts[0])
+	}
+
+	podcasts, err = pi.SearchByPerson("Jane")
+	if err != nil {
+		t.Fatalf("SearchByPerson() error = %v", err)
+	}
+	var names []string
+	for _, p := range podcasts {
+		names = append(names, p.Name)
+	}
+	if got := strings.Join(names, ", "); got != "Indie Show, Other Show" {
+		t.Errorf("SearchByPerson() = %q, want each podcast once", got)
+	}
+
+	if podcasts, err = pi.Trending(10); err != nil || len(podcasts) != 1 {
+		t.Errorf("Trending() = %v, %v", podcasts, err)
+	}
+
+	episodes, err := pi.EpisodesByFeed("https://example.com/feed.xml", 10)
+	if err != nil {
+		t.Fatalf("EpisodesByFeed() error = %v", err)
+	}
+	if len(episodes) != 1 || episodes[0].URL != "https://example.com/1.mp3" || episodes[0].Duration != 1800 || episodes[0].Date.Unix() != 1700000000 {
+		t.Errorf("EpisodesByFeed() = %+v", episodes[0])
+	}
+
+	if _, err := main.NewPodcastIndex(server.URL, "key", "wrong").SearchByTerm("indie"); err == nil {
+		t.Error("SearchByTerm() succeeded with a wrong secret")
+	}
+	if _, err := main.NewPodcastIndex(server.URL, "", "").SearchByTerm("indie"); err == nil {
+		t.Error("SearchByTerm() succeeded without credentials")
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func EditQueue(action string, selected []*Episode) ([]*Episode, error) {
+	// always edit the latest queue, so that changes made elsewhere are not overwritten
+	episodes, err := GetUpNext(true)
+	if err != nil {
+		return nil, err
+	}
+	switch action {
+	case "removeFromQueue":
+		return RemoveEpisodesFromQueue(selected)
+	case "clearQueue":
+		if len(episodes) == 0 {
+			return episodes, nil
+		}
+		return RemoveEpisodesFromQueue(episodes)
+	case "removePlayed":
+		played := make([]*Episode, 0)
+		for _, e := range episodes {
+			if e.IsPlayed() || (e.Duration > 0 && e.PlayedUpTo >= e.Duration) {
+				played = append(played, e)
+			}
+		}
+		if len(played) == 0 {
+			return episodes, nil
+		}
+		return RemoveEpisodesFromQueue(played)
+	case "dedupeQueue":
+		duplicates := findDuplicates(episodes)
+		if len(duplicates) == 0 {
+			return episodes, nil
+		}
+		return RemoveEpisodesFromQueue(duplicates)
+	case "shuffleQueue":
+		return ReplaceQueue(shuffleByPodcast(episodes))
+	case "moveToTop", "moveUp", "moveDown", "moveToBottom":
+		if len(selected) == 0 {
+			return nil, fmt.Errorf("no episode selected")
+		}
+		reordered, err := moveEpisode(episodes, selected[0].UUID, action)
+		if err != nil {
+			return nil, err
+		}
+		return ReplaceQueue(reordered)
+	default:
+		return nil, fmt.Errorf("invalid queue action: %s", action)
+	}
+}
+
+func moveEpisode(episodes []*Episode, uuid string, action string) ([]*Episode, error) {
+	i := slices.IndexFunc(episodes, func(e *Episode) bool { return e.UUID == uuid })
+	if i < 0 {
+		return nil, fmt.Errorf("episode not in queue")
+	}
+	e := episodes[i]
+	reordered := slices.Delete(slices.Clone(episodes), i, i+1)
+	var j int
+	switch action {
+	case "moveToTop":
+		j = 0
+	case "moveUp":
+		j = max(i-1, 0)
+	case "moveDown":
+		j = min(i+1, len(reordered))
+	default:
+		j = len(reordered)
+	}
+	return slices.Insert(reordered, j, e), nil
+}
+
+// findDuplicates returns every episode that repeats an earlier one, matched
+// by UUID, enclosure URL, or podcast and title
+func findDuplicates(episodes []*Episode) []*Episode {
+	seen := make(map[string]bool)
+	duplicates := make([]*Episode, 0)
+	for _, e := range episodes {
+		keys := []string{"uuid:" + e.UUID, "title:" + e.PodcastUUID + "\t" + e.Title}
+		if e.URL != "" {
+			keys = append(keys, "url:"+e.URL)
+		}
+		duplicate := false
+		for _, k := range keys {
+			if seen[k] {
+				duplicate = true
+			}
+			seen[k] = true
+		}
+		if duplicate {
+			duplicates = append(duplicates, e)
+		}
+	}
+	return duplicates
+}
+
+// shuffleByPodcast keeps the episode in progress on top, then takes one
+// episode from each podcast in turn, with the podcasts in random order
+func shuffleByPodcast(episodes []*Episode) []*Episode {
+	if len(episodes) < 2 {
+		return episodes
+	}
+	groups := make(map[string][]*Episode)
+	order := make([]string, 0)
+	for _, e := range episodes[1:] {
+		if _, ok := groups[e.PodcastUUID]; !ok {
+			order = append(order, e.PodcastUUID)
+		}
+		groups[e.PodcastUUID] = append(groups[e.PodcastUUID], e)
+	}
+	rand.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+	shuffled := []*Episode{episodes[0]}
+	for len(shuffled) < len(episodes) {
+		for _, uuid := range order {
+			if group := groups[uuid]; len(group) > 0 {
+				shuffled = append(shuffled, group[0])
+				groups[uuid] = group[1:]
+			}
+		}
+	}
+	return shuffled
+}
+
+// only recent episodes are picked from the back catalog
+const fillQueueMaxAge = 14 * 24 * time.Hour
+
+type QueueFillPlan struct {
+	Target   int // seconds
+	Queued   int // seconds left in the queue
+	Episodes []*Episode
+}
+
+func (plan *QueueFillPlan) Total() int {
+	total := plan.Queued
+	for _, e := range plan.Episodes {
+		total += e.Duration
+	}
+	return total
+}
+
+// podcastPriorities reads `[priorities]` of the config file and the
+// `podcastPriorities` workflow config, one `Podcast Name: priority` per line. Podcasts default to 0; negative
+// priorities are never picked.
+func podcastPriorities() map[string]int {
+	priorities := make(map[string]int)
+	for name, n := range config.Priorities {
+		priorities[strings.ToLower(name)] = n
+	}
+	for line := range strings.Lines(os.Getenv("podcastPriorities")) {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			priorities[strings.ToLower(strings.TrimSpace(name))] = n
+		}
+	}
+	return priorities
+}
+
+func PlanQueueFill(target int) (*QueueFillPlan, error) {
+	if target <= 0 {
+		return nil, fmt.Errorf("invalid target duration")
+	}
+	queue, err := GetUpNext(false)
+	if err != nil {
+		return nil, err
+	}
+	plan := &QueueFillPlan{Target: target}
+	for _, e := range queue {
+		plan.Queued += max(e.Duration-e.PlayedUpTo, 0)
+	}
+
+	candidates := make([]*Episode, 0)
+	seen := make(map[string]bool)
+	add := func(e *Episode) {
+		if _, ok := upNextMap[e.UUID]; ok || seen[e.UUID] {
+			return
+		}
+		seen[e.UUID] = true
+		candidates = append(candidates, e)
+	}
+	newReleases, err := GetList("new_releases", false)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range newReleases {
+		add(e)
+	}
+	if err := GetAllPodcasts(false); err != nil {
+		return nil, err
+	}
+	for _, p := range podcastMap {
+		for _, e := range p.EpisodeMap {
+			if time.Since(e.Date) < fillQueueMaxAge {
+				if e.Podcast == "" {
+					e.Podcast = p.Name
+				}
+				add(e)
+			}
+		}
+	}
+	// new releases carry no sync state
+	for _, e := range candidates {
+		if p, ok := podcastMap[e.PodcastUUID]; ok {
+			if _e, ok := p.EpisodeMap[e.UUID]; ok {
+				e.Status, e.Archived, e.PlayedUpTo = _e.Status, _e.Archived, _e.PlayedUpTo
+			}
+		}
+	}
+	plan.Episodes = PickEpisodes(candidates, podcastPriorities(), target-plan.Queued)
+	return plan, nil
+}
+
+// PickEpisodes chooses unplayed episodes that fit in `budget` seconds, by
+// priority, then newest first
+func PickEpisodes(candidates []*Episode, priorities map[string]int, budget int) []*Episode {
+	priority := func(e *Episode) int {
+		if n, ok := priorities[strings.ToLower(e.Podcast)]; ok {
+			return n
+		}
+		return priorities[strings.ToLower(e.PodcastUUID)]
+	}
+	eligible := make([]*Episode, 0, len(candidates))
+	for _, e := range candidates {
+		if e.IsUnplayed() && !e.Archived && e.Duration > 0 && priority(e) >= 0 {
+			eligible = append(eligible, e)
+		}
+	}
+	sort.SliceStable(eligible, func(i, j int) bool {
+		if pi, pj := priority(eligible[i]), priority(eligible[j]); pi != pj {
+			return pi > pj
+		}
+		return eligible[i].Date.After(eligible[j].Date)
+	})
+	picked := make([]*Episode, 0)
+	for _, e := range eligible {
+		if e.Duration <= budget {
+			picked = append(picked, e)
+			budget -= e.Duration
+		}
+	}
+	return picked
+}
+
+func FillQueue(target int) ([]*Episode, error) {
+	plan, err := PlanQueueFill(target)
+	if err != nil {
+		return nil, err
+	}
+	added := make([]*Episode, 0, len(plan.Episodes))
+	for _, e := range plan.Episodes {
+		if _, err := e.AddToQueue("play_last"); err != nil {
+			return added, err
+		}
+		added = append(added, e)
+	}
+	return added, nil
+}
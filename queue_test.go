@@ -0,0 +1,93 @@
+package main_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/twio142/alfred-podcasts"
+)
+
+func TestEditQueue(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		action   string
+		selected []*main.Episode
+		wantErr  bool
+	}{
+		{
+			name:    "valid dedupe queue",
+			action:  "dedupeQueue",
+			wantErr: false,
+		},
+		{
+			name:   "valid move to bottom",
+			action: "moveToBottom",
+			selected: []*main.Episode{
+				{UUID: "87ecf9da-1122-4ffd-98c0-c150a42c3268"},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "invalid move without selection",
+			action:  "moveUp",
+			wantErr: true,
+		},
+		{
+			name:    "invalid queue action",
+			action:  "nonsense",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := main.EditQueue(tt.action, tt.selected)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("EditQueue() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("EditQueue() succeeded unexpectedly")
+			}
+			if true {
+				fmt.Printf("EditQueue() = %v", got)
+			}
+		})
+	}
+}
+
+func TestPickEpisodes(t *testing.T) {
+	now := time.Now()
+	candidates := []*main.Episode{
+		{UUID: "a", Podcast: "Daily", Duration: 1800, Date: now},
+		{UUID: "b", Podcast: "Interviews", Duration: 5400, Date: now},
+		{UUID: "c", Podcast: "Daily", Duration: 1200, Date: now.Add(-time.Hour)},
+		{UUID: "d", Podcast: "Daily", Duration: 900, Date: now, Status: 3},
+		{UUID: "e", Podcast: "Noise", Duration: 600, Date: now},
+		{UUID: "f", Podcast: "Misc", Duration: 600, Date: now},
+	}
+	priorities := map[string]int{"daily": 2, "interviews": 1, "noise": -1}
+	tests := []struct {
+		name   string // description of this test case
+		budget int
+		want   []string
+	}{
+		{name: "priority order", budget: 3600, want: []string{"a", "c", "f"}},
+		{name: "skip what does not fit", budget: 8800, want: []string{"a", "c", "b"}},
+		{name: "no budget", budget: 0, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, e := range main.PickEpisodes(candidates, priorities, tt.budget) {
+				got = append(got, e.UUID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("PickEpisodes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
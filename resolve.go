@@ -0,0 +1,540 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// links are followed through at most this many hops, e.g. a short
+	// link redirecting to a page that links to the feed
+	resolveMaxDepth = 5
+	// pages are only read up to this size
+	resolveMaxBody = 2 << 20
+)
+
+var audioExtensions = []string{".mp3", ".m4a", ".aac", ".ogg", ".oga", ".opus", ".wav", ".flac", ".mp4"}
+
+// Resolved is what a pasted link points to: an episode, a podcast, or both
+type Resolved struct {
+	Episode  *Episode
+	Podcast  *Podcast
+	Position int // start time of the link, in seconds
+}
+
+// JSON is the episode with the start time of the link as `timestamp`
+func (r *Resolved) JSON() (string, error) {
+	if r.Episode == nil {
+		return "", fmt.Errorf("not an episode link")
+	}
+	data, err := json.Marshal(struct {
+		*Episode
+		Timestamp int `json:"timestamp,omitempty"`
+	}{r.Episode, r.Position})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+type urlResolver struct {
+	hosts   []string
+	resolve func(u *url.URL, depth int) (*Resolved, error)
+}
+
+// tried by host; anything else is fetched and sniffed by `resolveGeneric`
+var urlResolvers []urlResolver
+
+func init() {
+	urlResolvers = []urlResolver{
+		{[]string{"pocketcasts.com", "play.pocketcasts.com", "pca.st"}, resolvePocketCasts},
+		{[]string{"podcasts.apple.com", "itunes.apple.com"}, resolveApple},
+		{[]string{"overcast.fm"}, resolveOvercast},
+		{[]string{"podcastaddict.com"}, resolvePodcastAddict},
+	}
+}
+
+// ResolveURL finds the episode or podcast behind a Pocket Casts, Apple
+// Podcasts, Overcast, Castro or Podcast Addict link, an enclosure or a feed
+func ResolveURL(raw string) (*Resolved, error) {
+	return resolveURL(raw, 0)
+}
+
+func resolveURL(raw string, depth int) (*Resolved, error) {
+	if depth > resolveMaxDepth {
+		return nil, fmt.Errorf("too many redirects: %s", raw)
+	}
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || strings.ContainsAny(u.Host, " \t") {
+		return nil, fmt.Errorf("invalid URL: %s", raw)
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	resolve := resolveGeneric
+	for _, r := range urlResolvers {
+		if slices.Contains(r.hosts, host) {
+			resolve = r.resolve
+			break
+		}
+	}
+	r, err := resolve(u, depth)
+	// a redirect may drop the start time
+	if err == nil && r.Episode != nil && r.Position == 0 {
+		r.Position = LinkTimestamp(u.String())
+	}
+	return r, err
+}
+
+// LinkTimestamp reads the start time of a link in seconds: `?t=` or `#t=`
+// as seconds, `1:23` or `1m23s`, or the trailing `/1:23` of Overcast
+func LinkTimestamp(raw string) int {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return 0
+	}
+	values := []string{u.Query().Get("t")}
+	if fragment, err := url.ParseQuery(u.Fragment); err == nil {
+		values = append(values, fragment.Get("t"))
+	}
+	if strings.TrimPrefix(u.Hostname(), "www.") == "overcast.fm" {
+		if segments := strings.Split(strings.Trim(u.Path, "/"), "/"); len(segments) == 2 {
+			values = append(values, segments[1])
+		}
+	}
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		if n, err := parseTimestamp(v); err == nil {
+			return n
+		}
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return int(d.Seconds())
+		}
+	}
+	return 0
+}
+
+// fetch follows redirects and returns the final URL, the media type and up
+// to `resolveMaxBody` of the body
+func fetch(u string) (*url.URL, string, []byte, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	// some sites serve a bare page to unknown clients
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko)")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("error resolving URL: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 400 {
+		return nil, "", nil, fmt.Errorf("error resolving URL: %s", resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if strings.HasPrefix(mediaType, "audio/") || strings.HasPrefix(mediaType, "video/") {
+		// the enclosure itself, no need to download it
+		return resp.Request.URL, mediaType, nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, resolveMaxBody))
+	return resp.Request.URL, mediaType, body, err
+}
+
+// redirected re-resolves `final` when it is on another site
+func redirected(u, final *url.URL, depth int) (*Resolved, bool, error) {
+	if final == nil || strings.EqualFold(final.Hostname(), u.Hostname()) {
+		return nil, false, nil
+	}
+	r, err := resolveURL(final.String(), depth+1)
+	return r, true, err
+}
+
+func resolvePocketCasts(u *url.URL, depth int) (*Resolved, error) {
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	// /podcast/{podcast-slug}/{podcast-uuid}/{episode-slug}/{episode-uuid}
+	case len(segments) >= 5 && segments[0] == "podcast":
+		podcastUUID, episodeUUID, err := parseEpisodePath(u.String())
+		if err != nil {
+			return nil, err
+		}
+		e, err := fetchEpisode(podcastUUID, episodeUUID)
+		if err != nil {
+			return nil, err
+		}
+		return &Resolved{Episode: e, Podcast: podcastByUUID(e.PodcastUUID)}, nil
+	case len(segments) == 3 && segments[0] == "podcast":
+		return podcastResult(podcastByUUID(segments[2]))
+	// web player: /podcasts/{podcast-uuid}[/{episode-uuid}]
+	case len(segments) == 3 && segments[0] == "podcasts":
+		e, err := fetchEpisode(segments[1], segments[2])
+		if err != nil {
+			return nil, err
+		}
+		return &Resolved{Episode: e, Podcast: podcastByUUID(e.PodcastUUID)}, nil
+	case len(segments) == 2 && segments[0] == "podcasts":
+		return podcastResult(podcastByUUID(segments[1]))
+	}
+	// share links redirect to one of the above
+	final, _, _, err := fetch(u.String())
+	if err != nil {
+		return nil, err
+	}
+	if r, ok, err := redirected(u, final, depth); ok {
+		return r, err
+	}
+	return nil, fmt.Errorf("no redirect found for URL: %s", u)
+}
+
+func podcastByUUID(uuid string) *Podcast {
+	if err := GetPodcastList(false); err == nil {
+		if p, ok := podcastMap[uuid]; ok {
+			return p
+		}
+	}
+	p := &Podcast{UUID: uuid}
+	if err := p.GetInfo(); err != nil || p.Name == "" {
+		return nil
+	}
+	return p
+}
+
+func podcastResult(p *Podcast) (*Resolved, error) {
+	if p == nil {
+		return nil, fmt.Errorf("podcast not found")
+	}
+	return &Resolved{Podcast: p}, nil
+}
+
+type appleLookupResult struct {
+	Kind           string `json:"kind"`
+	CollectionName string `json:"collectionName"`
+	ArtistName     string `json:"artistName"`
+	FeedURL        string `json:"feedUrl"`
+	TrackID        int64  `json:"trackId"`
+	TrackName      string `json:"trackName"`
+	EpisodeURL     string `json:"episodeUrl"`
+}
+
+// appleLookup returns the podcast with the ID and its recent episodes
+func appleLookup(id string) (*appleLookupResult, []*appleLookupResult, error) {
+	_, _, body, err := fetch(fmt.Sprintf("https://itunes.apple.com/lookup?id=%s&entity=podcastEpisode&limit=200", url.QueryEscape(id)))
+	if err != nil {
+		return nil, nil, err
+	}
+	var response struct {
+		Results []*appleLookupResult `json:"results"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, nil, fmt.Errorf("invalid Apple Podcasts response: %v", err)
+	}
+	var podcast *appleLookupResult
+	episodes := make([]*appleLookupResult, 0)
+	for _, r := range response.Results {
+		switch r.Kind {
+		case "podcast":
+			podcast = r
+		case "podcast-episode":
+			episodes = append(episodes, r)
+		}
+	}
+	if podcast == nil || podcast.FeedURL == "" {
+		return nil, nil, fmt.Errorf("podcast not found on Apple Podcasts: %s", id)
+	}
+	return podcast, episodes, nil
+}
+
+var appleID = regexp.MustCompile(`(?:/id|/itunes)(\d+)`)
+
+// resolveAppleID finds the podcast through its feed, and the episode with
+// `episodeID` or else `enclosure`
+func resolveAppleID(id, episodeID, enclosure string) (*Resolved, error) {
+	podcast, episodes, err := appleLookup(id)
+	if err != nil {
+		return nil, err
+	}
+	itunesID, _ := strconv.Atoi(id)
+	p := podcastForFeed(podcast.FeedURL, podcast.CollectionName, itunesID)
+	title := ""
+	if episodeID != "" {
+		for _, e := range episodes {
+			if fmt.Sprint(e.TrackID) == episodeID {
+				enclosure, title = e.EpisodeURL, e.TrackName
+			}
+		}
+		if enclosure == "" && title == "" {
+			// older than the lookup goes back
+			return &Resolved{Podcast: p}, nil
+		}
+	}
+	return resolveParts(p, enclosure, title)
+}
+
+// https://podcasts.apple.com/us/podcast/{slug}/id{podcast-id}?i={episode-id}
+func resolveApple(u *url.URL, depth int) (*Resolved, error) {
+	m := appleID.FindStringSubmatch(u.Path)
+	if m == nil {
+		return nil, fmt.Errorf("no podcast ID in URL: %s", u)
+	}
+	return resolveAppleID(m[1], u.Query().Get("i"), "")
+}
+
+// https://overcast.fm/itunes{podcast-id}/{slug} or https://overcast.fm/+{episode-id}
+func resolveOvercast(u *url.URL, depth int) (*Resolved, error) {
+	if m := appleID.FindStringSubmatch(u.Path); m != nil {
+		return resolveAppleID(m[1], "", "")
+	}
+	return resolvePage(u, depth)
+}
+
+// https://podcastaddict.com/episode/{escaped-enclosure-url}
+func resolvePodcastAddict(u *url.URL, depth int) (*Resolved, error) {
+	if rest, ok := strings.CutPrefix(u.EscapedPath(), "/episode/"); ok {
+		if enclosure, err := url.PathUnescape(rest); err == nil && strings.HasPrefix(enclosure, "http") {
+			return resolveParts(nil, enclosure, "")
+		}
+	}
+	return resolvePage(u, depth)
+}
+
+func hasAudioExtension(p string) bool {
+	return slices.Contains(audioExtensions, strings.ToLower(path.Ext(p)))
+}
+
+// resolveGeneric handles enclosures, feeds and pages with an audio player
+func resolveGeneric(u *url.URL, depth int) (*Resolved, error) {
+	if hasAudioExtension(u.Path) {
+		return resolveParts(nil, u.String(), "")
+	}
+	return resolvePage(u, depth)
+}
+
+func resolvePage(u *url.URL, depth int) (*Resolved, error) {
+	final, mediaType, body, err := fetch(u.String())
+	if err != nil {
+		return nil, err
+	}
+	if r, ok, err := redirected(u, final, depth); ok {
+		return r, err
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "audio/"), strings.HasPrefix(mediaType, "video/"):
+		return resolveParts(nil, u.String(), "")
+	case strings.Contains(mediaType, "xml") || strings.Contains(mediaType, "rss"):
+		return &Resolved{Podcast: podcastForFeed(u.String(), feedName(body), 0)}, nil
+	}
+	page := ScrapePage(string(body))
+	switch {
+	case page.AppleID != "":
+		return resolveAppleID(page.AppleID, "", page.Enclosure)
+	case page.Feed != "":
+		base := final
+		if base == nil {
+			base = u
+		}
+		feed := page.Feed
+		if ref, err := base.Parse(feed); err == nil {
+			feed = ref.String()
+		}
+		p := podcastForFeed(feed, "", 0)
+		if page.Enclosure == "" {
+			return &Resolved{Podcast: p}, nil
+		}
+		return resolveParts(p, page.Enclosure, page.Title)
+	case page.Enclosure != "":
+		return resolveParts(nil, page.Enclosure, "")
+	}
+	return nil, fmt.Errorf("no podcast or episode found at %s", u)
+}
+
+// Page is what `ScrapePage` found in an episode or podcast page
+type Page struct {
+	Title     string
+	Enclosure string
+	Feed      string
+	AppleID   string
+}
+
+var (
+	pageTitle     = regexp.MustCompile(`<meta[^>]+(?:property|name)="og:title"[^>]+content="([^"]*)"`)
+	pageEnclosure = []*regexp.Regexp{
+		regexp.MustCompile(`<meta[^>]+(?:property|name)="og:audio(?::(?:secure_)?url)?"[^>]+content="([^"]+)"`),
+		regexp.MustCompile(`<(?:audio|source)[^>]+src="([^"]+)"`),
+	}
+	pageFeed = []*regexp.Regexp{
+		regexp.MustCompile(`<link[^>]+type="application/rss\+xml"[^>]+href="([^"]+)"`),
+		regexp.MustCompile(`<link[^>]+href="([^"]+)"[^>]+type="application/rss\+xml"`),
+	}
+	pageAppleID = regexp.MustCompile(`(?:podcasts\.apple\.com/[^"'\s]*?/id|itunes\.apple\.com/[^"'\s]*?/id|href="/itunes)(\d+)`)
+)
+
+// ScrapePage reads the enclosure, feed, Apple Podcasts ID and title from
+// the HTML of a podcast app's web page
+func ScrapePage(body string) *Page {
+	page := &Page{}
+	if m := pageTitle.FindStringSubmatch(body); m != nil {
+		page.Title = html.UnescapeString(m[1])
+	}
+	for _, re := range pageEnclosure {
+		if m := re.FindStringSubmatch(body); m != nil {
+			enclosure := html.UnescapeString(m[1])
+			// players start at `#t=`
+			enclosure, _, _ = strings.Cut(enclosure, "#")
+			page.Enclosure = enclosure
+			break
+		}
+	}
+	for _, re := range pageFeed {
+		if m := re.FindStringSubmatch(body); m != nil {
+			page.Feed = html.UnescapeString(m[1])
+			break
+		}
+	}
+	if m := pageAppleID.FindStringSubmatch(body); m != nil {
+		page.AppleID = m[1]
+	}
+	return page
+}
+
+var feedTitle = regexp.MustCompile(`(?s)<channel>.*?<title>(?:<!\[CDATA\[)?(.*?)(?:\]\]>)?</title>`)
+
+// feedName reads the channel title of a feed
+func feedName(body []byte) string {
+	if m := feedTitle.FindSubmatch(body); m != nil {
+		return strings.TrimSpace(html.UnescapeString(string(m[1])))
+	}
+	return ""
+}
+
+// podcastForFeed finds the podcast of a feed among the subscribed ones, then
+// in the Pocket Casts search by `name`. Otherwise the podcast has only the
+// feed, which is added to Pocket Casts when subscribing.
+func podcastForFeed(feed, name string, itunesID int) *Podcast {
+	if name == "" {
+		if _, _, body, err := fetch(feed); err == nil {
+			name = feedName(body)
+		}
+	}
+	probe := &Podcast{Name: name, URL: feed, ITunesID: itunesID}
+	if err := GetPodcastList(false); err == nil {
+		for _, p := range podcastMap {
+			if samePodcast(p, probe) {
+				return p
+			}
+		}
+	}
+	if name != "" {
+		if podcasts, err := searchPodcasts(name); err == nil {
+			for _, p := range podcasts {
+				if strings.EqualFold(p.Name, name) {
+					return p
+				}
+			}
+		}
+	}
+	return probe
+}
+
+// sameEnclosure compares enclosure URLs without scheme, query and fragment
+func sameEnclosure(a, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Host, ub.Host) && ua.Path == ub.Path
+}
+
+// sameFileName compares the audio file names of enclosure URLs. Tracking
+// redirects such as `dts.podtrac.com/redirect.mp3/host/file.mp3` keep the
+// file name, but names like `episode.mp3` are only unique within a podcast.
+func sameFileName(a, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return hasAudioExtension(ua.Path) && path.Base(ua.Path) == path.Base(ub.Path)
+}
+
+// findEpisode looks for the enclosure, then for its file name, then for the
+// title, in `p`
+func (p *Podcast) findEpisode(enclosure, title string) *Episode {
+	if p.UUID == "" {
+		return nil
+	}
+	if err := p.GetEpisodes(false); err != nil {
+		return nil
+	}
+	if enclosure != "" {
+		for _, same := range []func(a, b string) bool{sameEnclosure, sameFileName} {
+			for _, e := range p.EpisodeMap {
+				if same(e.URL, enclosure) {
+					return e
+				}
+			}
+		}
+	}
+	if title != "" {
+		for _, e := range p.EpisodeMap {
+			if strings.EqualFold(strings.TrimSpace(e.Title), strings.TrimSpace(title)) {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// resolveParts finds the episode in `p`, or by its exact enclosure among the
+// subscribed podcasts when the podcast is not known
+func resolveParts(p *Podcast, enclosure, title string) (*Resolved, error) {
+	var e *Episode
+	if p != nil {
+		e = p.findEpisode(enclosure, title)
+	} else if enclosure != "" {
+		e = episodeByEnclosure(enclosure)
+	}
+	switch {
+	case e != nil:
+		if p == nil {
+			p = podcastByUUID(e.PodcastUUID)
+		}
+		return &Resolved{Episode: e, Podcast: p}, nil
+	case p != nil:
+		return &Resolved{Podcast: p}, nil
+	case enclosure != "":
+		return nil, fmt.Errorf("episode not found in subscribed podcasts: %s", enclosure)
+	}
+	return nil, fmt.Errorf("episode not found")
+}
+
+func episodeByEnclosure(enclosure string) *Episode {
+	if e := episodeByURL(enclosure); e != nil {
+		return e
+	}
+	_ = GetAllPodcasts(false)
+	for _, p := range podcastMap {
+		for _, e := range p.EpisodeMap {
+			if sameEnclosure(e.URL, enclosure) {
+				return e
+			}
+		}
+	}
+	return nil
+}
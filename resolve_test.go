@@ -0,0 +1,100 @@
+package main_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/twio142/alfred-podcasts"
+)
+
+func TestScrapePage(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		body string
+		want *main.Page
+	}{
+		{
+			name: "overcast episode",
+			body: `<html><head><meta name="og:title" content="Episode 12 &mdash; Show"></head><body>
+<a href="/itunes1234567/show">Show</a>
+<audio id="audioplayer" preload="none" controls><source src="https://cdn.example.com/ep12.mp3?src=ovc&amp;x=1#t=0" type="audio/mpeg"></audio>`,
+			want: &main.Page{Title: "Episode 12 — Show", Enclosure: "https://cdn.example.com/ep12.mp3?src=ovc&x=1", AppleID: "1234567"},
+		},
+		{
+			name: "og:audio and feed",
+			body: `<meta property="og:audio" content="https://cdn.example.com/ep.m4a"><link rel="alternate" href="/feed.xml" type="application/rss+xml">`,
+			want: &main.Page{Enclosure: "https://cdn.example.com/ep.m4a", Feed: "/feed.xml"},
+		},
+		{
+			name: "apple link",
+			body: `<a href="https://podcasts.apple.com/us/podcast/the-show/id987654321">Apple Podcasts</a>`,
+			want: &main.Page{AppleID: "987654321"},
+		},
+		{name: "nothing", body: `<html><body>Hello</body></html>`, want: &main.Page{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := main.ScrapePage(tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ScrapePage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		link string
+	}{
+		{name: "empty", link: ""},
+		{name: "not a URL", link: "hello world"},
+		{name: "apple without ID", link: "https://podcasts.apple.com/us/browse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := main.ResolveURL(tt.link); err == nil {
+				t.Errorf("ResolveURL() = %+v, want error", got)
+			}
+		})
+	}
+}
+
+func TestLinkTimestamp(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		link string
+		want int
+	}{
+		{name: "seconds", link: "https://pca.st/episode/abc?t=754", want: 754},
+		{name: "fragment", link: "https://pocketcasts.com/podcast/a/b/c/d#t=12:34", want: 754},
+		{name: "duration", link: "https://example.com/ep.mp3?t=1h2m3s", want: 3723},
+		{name: "overcast", link: "https://overcast.fm/+AbCdEf/12:34", want: 754},
+		{name: "overcast podcast", link: "https://overcast.fm/itunes123/show", want: 0},
+		{name: "none", link: "https://pca.st/abc", want: 0},
+		{name: "invalid", link: "https://pca.st/abc?t=soon", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := main.LinkTimestamp(tt.link); got != tt.want {
+				t.Errorf("LinkTimestamp() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolved_JSON(t *testing.T) {
+	r := &main.Resolved{Episode: &main.Episode{Title: "Ep", UUID: "e-uuid"}, Position: 90}
+	got, err := r.JSON()
+	if err != nil {
+		t.Fatalf("JSON() error = %v", err)
+	}
+	for _, want := range []string{`"title":"Ep"`, `"uuid":"e-uuid"`, `"timestamp":90`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("JSON() = %s, want %s", got, want)
+		}
+	}
+	if _, err := (&main.Resolved{Podcast: &main.Podcast{}}).JSON(); err == nil {
+		t.Error("JSON() of a podcast link succeeded unexpectedly")
+	}
+}
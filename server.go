@@ -0,0 +1,568 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultServeAddr = "127.0.0.1:8750"
+	// how often the queue and the player are checked for server-sent events
+	eventInterval = 5 * time.Second
+)
+
+// apiToken is taken from `PODCASTS_API_TOKEN`, or generated once and kept
+// in the cache directory
+func apiToken() (string, error) {
+	if token := os.Getenv("PODCASTS_API_TOKEN"); token != "" {
+		return token, nil
+	}
+	file := getCachePath("api_token")
+	if data, err := os.ReadFile(file); err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		return strings.TrimSpace(string(data)), nil
+	}
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	if err := os.WriteFile(file, []byte(token+"\n"), 0o600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+type apiError struct {
+	status int
+	msg    string
+}
+
+func (e *apiError) Error() string {
+	return e.msg
+}
+
+func badRequest(format string, a ...any) error {
+	return &apiError{http.StatusBadRequest, fmt.Sprintf(format, a...)}
+}
+
+func notFound(format string, a ...any) error {
+	return &apiError{http.StatusNotFound, fmt.Sprintf(format, a...)}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// event is sent to the `/api/events` subscribers
+type event struct {
+	Type string `json:"type"` // queue or playback
+	Data any    `json:"data"`
+}
+
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan event]bool
+}
+
+func (h *eventHub) subscribe() chan event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan event, 8)
+	h.subscribers[ch] = true
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers, ch)
+}
+
+func (h *eventHub) publish(ev event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			// a slow client misses the event rather than blocking the others
+		}
+	}
+}
+
+type playbackState struct {
+	Episode  *Episode `json:"episode,omitempty"`
+	Paused   bool     `json:"paused"`
+	Position int      `json:"position"`
+}
+
+type apiServer struct {
+	token string
+	// the library lives in package-level maps, so requests take turns
+	mu     sync.Mutex
+	events *eventHub
+	// last published state, to send only changes
+	queue    []string
+	playback string
+	// when the podcast list was last read, to pick up subscription changes
+	podcastsLoaded time.Time
+}
+
+// NewAPIHandler serves the library as JSON under `/api/`. Every request
+// needs `Authorization: Bearer <token>`; `/api/events` also takes
+// `?token=`, since EventSource cannot set headers.
+func NewAPIHandler(token string) http.Handler {
+	return newAPIServer(token).routes()
+}
+
+func newAPIServer(token string) *apiServer {
+	return &apiServer{token: token, events: &eventHub{subscribers: make(map[chan event]bool)}}
+}
+
+func (s *apiServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/podcasts", s.handle(s.podcasts))
+	mux.HandleFunc("GET /api/podcasts/{uuid}/episodes", s.handle(s.episodes))
+	mux.HandleFunc("GET /api/queue", s.handle(s.queueList))
+	mux.HandleFunc("POST /api/queue", s.handle(s.queueAdd))
+	mux.HandleFunc("DELETE /api/queue", s.handle(s.queueRemove))
+	mux.HandleFunc("GET /api/lists/{list}", s.handle(s.list))
+	mux.HandleFunc("GET /api/search", s.handle(s.search))
+	mux.HandleFunc("POST /api/archive", s.handle(s.archive))
+	mux.HandleFunc("POST /api/played", s.handle(s.played))
+	mux.HandleFunc("POST /api/play", s.handle(s.play))
+	mux.HandleFunc("GET /api/playing", s.handle(s.playing))
+	mux.HandleFunc("GET /api/events", s.authorized(s.stream))
+	return mux
+}
+
+func (s *apiServer) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok && r.URL.Path == "/api/events" {
+			token = r.URL.Query().Get("token")
+		}
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handle runs `fn` under the library lock and encodes its result
+func (s *apiServer) handle(fn func(r *http.Request) (any, error)) http.HandlerFunc {
+	return s.authorized(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		v, err := fn(r)
+		s.mu.Unlock()
+		if err != nil {
+			status := http.StatusBadGateway
+			var e *apiError
+			if errors.As(err, &e) {
+				status = e.status
+			}
+			writeJSON(w, status, map[string]string{"error": err.Error()})
+			return
+		}
+		if v == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, http.StatusOK, v)
+	})
+}
+
+func limitParam(r *http.Request, fallback int) (int, error) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, badRequest("invalid limit: %s", v)
+	}
+	return n, nil
+}
+
+func limitEpisodes(episodes []*Episode, limit int) []*Episode {
+	if limit > 0 && len(episodes) > limit {
+		episodes = episodes[:limit]
+	}
+	return withoutShowNotes(episodes)
+}
+
+// episodesBody reads `{"episodes": ["<podcast-uuid>/<episode-uuid>", ...]}`
+// plus any other fields into `extra`
+func episodesBody(r *http.Request, extra any) ([]*Episode, error) {
+	var body struct {
+		Episodes []string `json:"episodes"`
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, badRequest("%v", err)
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, badRequest("invalid body: %v", err)
+	}
+	if extra != nil {
+		if err := json.Unmarshal(data, extra); err != nil {
+			return nil, badRequest("invalid body: %v", err)
+		}
+	}
+	if len(body.Episodes) == 0 {
+		return nil, badRequest("no episodes given")
+	}
+	episodes, err := parseEpisodeRefs(body.Episodes)
+	if err != nil {
+		return nil, badRequest("%v", err)
+	}
+	for i, e := range episodes {
+		// episodes missing from the cache come back without details
+		if e.URL == "" {
+			return nil, notFound("episode not found: %s", body.Episodes[i])
+		}
+	}
+	return episodes, nil
+}
+
+// loadPodcasts rereads the podcast list once its cache file has changed or
+// expired, as the server outlives the list kept in memory
+func (s *apiServer) loadPodcasts() error {
+	info, err := os.Stat(getCachePath("podcast_list"))
+	if err != nil || info.ModTime().After(s.podcastsLoaded) || time.Since(s.podcastsLoaded) > time.Duration(config.Cache.PodcastList) {
+		podcastMap = nil
+		s.podcastsLoaded = time.Now()
+	}
+	return GetPodcastList(false)
+}
+
+func (s *apiServer) podcasts(r *http.Request) (any, error) {
+	if err := s.loadPodcasts(); err != nil {
+		return nil, err
+	}
+	podcasts := make([]*Podcast, 0, len(podcastMap))
+	for _, p := range podcastMap {
+		_p := *p
+		_p.EpisodeMap = nil
+		podcasts = append(podcasts, &_p)
+	}
+	slices.SortFunc(podcasts, func(a, b *Podcast) int {
+		return b.LastUpdated.Compare(a.LastUpdated)
+	})
+	return podcasts, nil
+}
+
+func (s *apiServer) episodes(r *http.Request) (any, error) {
+	if err := s.loadPodcasts(); err != nil {
+		return nil, err
+	}
+	p, ok := podcastMap[r.PathValue("uuid")]
+	if !ok {
+		return nil, notFound("podcast not found: %s", r.PathValue("uuid"))
+	}
+	limit, err := limitParam(r, config.Episodes.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.GetEpisodes(false); err != nil {
+		return nil, err
+	}
+	mode := r.URL.Query().Get("sort")
+	if mode == "" {
+		mode = episodeSortModes[0]
+	} else if !slices.Contains(episodeSortModes, mode) {
+		return nil, badRequest("invalid sort: %s", mode)
+	}
+	term := r.URL.Query().Get("q")
+	archived := r.URL.Query().Get("archived") == "true"
+	episodes := make([]*Episode, 0)
+	for _, e := range p.FilterEpisodes(term, mode) {
+		if archived || !e.Archived {
+			episodes = append(episodes, e)
+		}
+	}
+	return limitEpisodes(episodes, limit), nil
+}
+
+func (s *apiServer) queueList(r *http.Request) (any, error) {
+	episodes, err := GetUpNext(false)
+	if err != nil {
+		return nil, err
+	}
+	return withoutShowNotes(episodes), nil
+}
+
+// queueAdd takes `position`: `next` or `last` (default)
+func (s *apiServer) queueAdd(r *http.Request) (any, error) {
+	var extra struct {
+		Position string `json:"position"`
+	}
+	episodes, err := episodesBody(r, &extra)
+	if err != nil {
+		return nil, err
+	}
+	action := "play_last"
+	switch extra.Position {
+	case "", "last":
+	case "next":
+		action = "play_next"
+	default:
+		return nil, badRequest("invalid position: %s", extra.Position)
+	}
+	var queue []*Episode
+	for _, e := range episodes {
+		if queue, err = e.AddToQueue(action); err != nil {
+			return nil, fmt.Errorf("%s: %v", e.UUID, err)
+		}
+	}
+	s.publishQueue(queue)
+	return withoutShowNotes(queue), nil
+}
+
+func (s *apiServer) queueRemove(r *http.Request) (any, error) {
+	episodes, err := episodesBody(r, nil)
+	if err != nil {
+		return nil, err
+	}
+	queue, err := RemoveEpisodesFromQueue(episodes)
+	if err != nil {
+		return nil, err
+	}
+	s.publishQueue(queue)
+	return withoutShowNotes(queue), nil
+}
+
+func (s *apiServer) list(r *http.Request) (any, error) {
+	list := r.PathValue("list")
+	if list != "new_releases" && list != "history" {
+		return nil, notFound("list not found: %s", list)
+	}
+	limit, err := limitParam(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	episodes, err := GetList(list, false)
+	if err != nil {
+		return nil, err
+	}
+	return limitEpisodes(episodes, limit), nil
+}
+
+func (s *apiServer) search(r *http.Request) (any, error) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		return nil, badRequest("missing query: q")
+	}
+	limit, err := limitParam(r, 50)
+	if err != nil {
+		return nil, err
+	}
+	search := SearchEpisodes
+	if r.URL.Query().Get("remote") == "true" {
+		search = SearchAllEpisodes
+	}
+	results, err := search(query)
+	if err != nil {
+		return nil, err
+	}
+	episodes := make([]*Episode, len(results))
+	for i, r := range results {
+		episodes[i] = r.Episode
+	}
+	return limitEpisodes(episodes, limit), nil
+}
+
+func (s *apiServer) archive(r *http.Request) (any, error) {
+	var extra struct {
+		Played bool `json:"played"`
+	}
+	episodes, err := episodesBody(r, &extra)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.archiveEpisodes(episodes, extra.Played)
+}
+
+func (s *apiServer) played(r *http.Request) (any, error) {
+	episodes, err := episodesBody(r, nil)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.archiveEpisodes(episodes, true)
+}
+
+// archiveEpisodes also takes the episodes off Up Next, so the subscribers
+// hear about it
+func (s *apiServer) archiveEpisodes(episodes []*Episode, markAsPlayed bool) error {
+	if err := ArchiveEpisodes(episodes, markAsPlayed); err != nil {
+		return err
+	}
+	if queue, err := GetUpNext(false); err == nil {
+		s.publishQueue(queue)
+	}
+	return nil
+}
+
+// play loads an episode into the player; `position` is `now` (default),
+// `next` or `last`
+func (s *apiServer) play(r *http.Request) (any, error) {
+	var body struct {
+		Episode  string `json:"episode"`
+		Position string `json:"position"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&body); err != nil {
+		return nil, badRequest("invalid body: %v", err)
+	}
+	if !slices.Contains([]string{"", "now", "next", "last"}, body.Position) {
+		return nil, badRequest("invalid position: %s", body.Position)
+	}
+	episodes, err := parseEpisodeRefs([]string{body.Episode})
+	if err != nil {
+		return nil, badRequest("%v", err)
+	}
+	if episodes[0].URL == "" {
+		return nil, notFound("episode not found: %s", body.Episode)
+	}
+	if err := PlayEpisode(episodes[0].URL, body.Position); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (s *apiServer) playing(r *http.Request) (any, error) {
+	return getPlayback(true), nil
+}
+
+// getPlayback asks the player what is playing; the zero state means
+// nothing is. Without `library` only the playlist and Up Next are searched.
+func getPlayback(library bool) *playbackState {
+	state := &playbackState{}
+	if e, np, err := getNowPlaying(library); err == nil {
+		_e := *e
+		_e.ShowNotes = ""
+		state.Episode = &_e
+		state.Paused = np.Paused
+		state.Position = int(np.Position)
+	}
+	return state
+}
+
+// publishQueue sends the queue when it has changed since last time
+func (s *apiServer) publishQueue(queue []*Episode) {
+	uuids := make([]string, len(queue))
+	for i, e := range queue {
+		uuids[i] = e.UUID
+	}
+	if slices.Equal(uuids, s.queue) {
+		return
+	}
+	s.queue = uuids
+	s.events.publish(event{"queue", withoutShowNotes(queue)})
+}
+
+// publishPlayback sends the player state when the episode or pause state
+// has changed; the position alone is not news
+func (s *apiServer) publishPlayback(state *playbackState) {
+	key := fmt.Sprint(state.Paused)
+	if state.Episode != nil {
+		key += state.Episode.UUID
+	}
+	if key == s.playback {
+		return
+	}
+	s.playback = key
+	s.events.publish(event{"playback", state})
+}
+
+// watch polls the queue and the player for changes made elsewhere
+func (s *apiServer) watch(done <-chan struct{}) {
+	ticker := time.NewTicker(eventInterval)
+	defer ticker.Stop()
+	for {
+		s.mu.Lock()
+		if queue, err := GetUpNext(false); err == nil {
+			s.publishQueue(queue)
+		}
+		// polled every few seconds, too often to scan all episodes
+		s.publishPlayback(getPlayback(false))
+		s.mu.Unlock()
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// stream sends `queue` and `playback` server-sent events
+func (s *apiServer) stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming unsupported"})
+		return
+	}
+	ch := s.events.subscribe()
+	defer s.events.unsubscribe(ch)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case ev := <-ch:
+			data, _ := json.Marshal(ev.Data)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+		}
+		flusher.Flush()
+	}
+}
+
+// Serve runs the API on a loopback address until the server fails
+func Serve(addr string, out io.Writer) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address: %s", addr)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("refusing to listen on %s: only loopback addresses are allowed", host)
+	}
+	token, err := apiToken()
+	if err != nil {
+		return fmt.Errorf("failed to create token: %v", err)
+	}
+	s := newAPIServer(token)
+	done := make(chan struct{})
+	defer close(done)
+	go s.watch(done)
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	fmt.Fprintf(out, "Serving on http://%s/api/\nToken: %s\n", addr, token)
+	return server.ListenAndServe()
+}
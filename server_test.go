@@ -0,0 +1,70 @@
+package main_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/twio142/alfred-podcasts"
+)
+
+func TestNewAPIHandler(t *testing.T) {
+	tests := []struct {
+		name       string // description of this test case
+		method     string
+		path       string
+		token      string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "no token", method: "GET", path: "/api/queue", wantStatus: 401, wantBody: "unauthorized"},
+		{name: "wrong token", method: "GET", path: "/api/queue", token: "nope", wantStatus: 401},
+		{name: "token in query only for events", method: "GET", path: "/api/queue?token=secret", wantStatus: 401},
+		{name: "unknown route", method: "GET", path: "/api/bogus", token: "secret", wantStatus: 404},
+		{name: "unknown list", method: "GET", path: "/api/lists/bogus", token: "secret", wantStatus: 404, wantBody: "list not found"},
+		{name: "search without query", method: "GET", path: "/api/search", token: "secret", wantStatus: 400, wantBody: "missing query"},
+		{name: "invalid body", method: "POST", path: "/api/queue", token: "secret", body: "{", wantStatus: 400, wantBody: "invalid body"},
+		{name: "no episodes", method: "POST", path: "/api/archive", token: "secret", body: `{"episodes":[]}`, wantStatus: 400, wantBody: "no episodes"},
+		{name: "invalid episode", method: "DELETE", path: "/api/queue", token: "secret", body: `{"episodes":["abc"]}`, wantStatus: 400, wantBody: "invalid episode"},
+		{name: "invalid position", method: "POST", path: "/api/play", token: "secret", body: `{"episode":"a/b","position":"soon"}`, wantStatus: 400, wantBody: "invalid position"},
+		{name: "wrong method", method: "PUT", path: "/api/played", token: "secret", wantStatus: 405},
+	}
+	handler := main.NewAPIHandler("secret")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAPIEvents(t *testing.T) {
+	server := httptest.NewServer(main.NewAPIHandler("secret"))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/events?token=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %v, want text/event-stream", got)
+	}
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || line != ": connected\n" {
+		t.Errorf("first line = %q, %v", line, err)
+	}
+}
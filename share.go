@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// share formats, in the order they are offered
+var shareFormats = []string{"url", "timestamp", "markdown", "rich"}
+
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// ShareURL is the episode page on pocketcasts.com, in the form read by
+// `parseEpisodePath`, starting at `position` seconds when it is positive
+func (e *Episode) ShareURL(position int) string {
+	podcastSlug, episodeSlug := slugify(e.Podcast), slugify(e.Title)
+	if podcastSlug == "" {
+		podcastSlug = "podcast"
+	}
+	if episodeSlug == "" {
+		episodeSlug = "episode"
+	}
+	u := fmt.Sprintf("https://pocketcasts.com/podcast/%s/%s/%s/%s", podcastSlug, e.PodcastUUID, episodeSlug, e.UUID)
+	if position > 0 {
+		u += fmt.Sprintf("?t=%d", position)
+	}
+	return u
+}
+
+// sharePosition is the player position when the episode is playing,
+// otherwise the synced progress
+func (e *Episode) sharePosition() int {
+	if c, np, err := GetNowPlaying(); err == nil && c.UUID == e.UUID {
+		return int(np.Position)
+	}
+	if e.IsPlayed() {
+		return 0
+	}
+	return e.PlayedUpTo
+}
+
+// ShareText renders the episode as `url`, `timestamp` (URL at `position`),
+// `markdown` or `rich`
+func (e *Episode) ShareText(format string, position int) (string, error) {
+	switch format {
+	case "url":
+		return e.ShareURL(0), nil
+	case "timestamp":
+		return e.ShareURL(position), nil
+	case "markdown":
+		return fmt.Sprintf("[%s — %s](%s)", escapeMarkdown(e.Title), escapeMarkdown(e.Podcast), e.ShareURL(0)), nil
+	case "rich":
+		details := []string{e.Podcast}
+		if !e.Date.IsZero() {
+			details = append(details, e.Date.Format("January 2, 2006"))
+		}
+		if e.Duration > 0 {
+			details = append(details, formatDuration(e.Duration))
+		}
+		u := e.ShareURL(0)
+		if position > 0 {
+			details = append(details, "from "+formatTimestamp(position))
+			u = e.ShareURL(position)
+		}
+		return fmt.Sprintf("%s\n%s\n%s", e.Title, strings.Join(details, " · "), u), nil
+	}
+	return "", fmt.Errorf("invalid share format: %s", format)
+}
+
+func escapeMarkdown(s string) string {
+	return strings.NewReplacer(`[`, `\[`, `]`, `\]`).Replace(s)
+}
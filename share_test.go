@@ -0,0 +1,44 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/twio142/alfred-podcasts"
+)
+
+func TestEpisode_ShareText(t *testing.T) {
+	e := &main.Episode{
+		Title:       "Episode 12: [Live] Q&A",
+		Podcast:     "Café Talk",
+		PodcastUUID: "p-uuid",
+		UUID:        "e-uuid",
+		Date:        time.Date(2025, 3, 4, 8, 0, 0, 0, time.UTC),
+		Duration:    3725,
+	}
+	tests := []struct {
+		name     string // description of this test case
+		format   string
+		position int
+		want     string
+		wantErr  bool
+	}{
+		{name: "url", format: "url", position: 90, want: "https://pocketcasts.com/podcast/café-talk/p-uuid/episode-12-live-q-a/e-uuid"},
+		{name: "timestamp", format: "timestamp", position: 90, want: "https://pocketcasts.com/podcast/café-talk/p-uuid/episode-12-live-q-a/e-uuid?t=90"},
+		{name: "timestamp without position", format: "timestamp", want: "https://pocketcasts.com/podcast/café-talk/p-uuid/episode-12-live-q-a/e-uuid"},
+		{name: "markdown", format: "markdown", want: `[Episode 12: \[Live\] Q&A — Café Talk](https://pocketcasts.com/podcast/café-talk/p-uuid/episode-12-live-q-a/e-uuid)`},
+		{name: "rich", format: "rich", position: 754, want: "Episode 12: [Live] Q&A\nCafé Talk · March 4, 2025 · 1:02:05 · from 12:34\nhttps://pocketcasts.com/podcast/café-talk/p-uuid/episode-12-live-q-a/e-uuid?t=754"},
+		{name: "invalid format", format: "html", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := e.ShareText(tt.format, tt.position)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ShareText() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ShareText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// bump to regenerate every cached show notes file
+const shownotesVersion = 3
+
+// `runtrigger` URL of the workflow's `seek` external trigger
+const seekURL = "alfred://runtrigger/com.twio142.podcasts/seek/?argument="
+
+// seekArg is the argument of the `seek` action: `seconds`, in `episode`
+// (`podcastUuid/uuid`) when known, so another episode playing is not moved
+func seekArg(episode string, seconds int) string {
+	if episode == "" {
+		return fmt.Sprintf("%d", seconds)
+	}
+	return fmt.Sprintf("%s@%d", episode, seconds)
+}
+
+// parseSeekArg reads `seekArg`
+func parseSeekArg(arg string) (episode string, seconds int, err error) {
+	episode, ts, ok := strings.Cut(arg, "@")
+	if !ok {
+		episode, ts = "", arg
+	}
+	seconds, err = parseTimestamp(ts)
+	return episode, seconds, err
+}
+
+var timestampRegexp = regexp.MustCompile(`\b(?:\d{1,2}:)?\d{1,2}:\d{2}\b`)
+
+// indentMark stands for the indentation of list items until the end, so
+// `cleanMarkdown` tells it from the spaces of the HTML. The parser never
+// returns NUL in text.
+const indentMark = "\x00"
+
+func shownotesHeader(showNotes string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(showNotes))
+	return fmt.Sprintf("<!-- shownotes v%d %08x -->", shownotesVersion, h.Sum32())
+}
+
+type mdContext struct {
+	episode string // `podcastUuid/uuid` of the timestamps
+	inLink  bool
+	inPre   bool
+	lists   []*mdList
+}
+
+type mdList struct {
+	ordered bool
+	index   int
+}
+
+// RenderShownotes converts the HTML show notes to Markdown, linking the
+// timestamps to `episode` (`podcastUuid/uuid`)
+func RenderShownotes(source, episode string) (string, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(source), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	c := &mdContext{episode: episode}
+	for _, n := range nodes {
+		b.WriteString(renderNode(n, c))
+	}
+	return strings.ReplaceAll(cleanMarkdown(b.String()), indentMark, " "), nil
+}
+
+func renderChildren(n *html.Node, c *mdContext) string {
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(renderNode(child, c))
+	}
+	return b.String()
+}
+
+func renderNode(n *html.Node, c *mdContext) string {
+	switch n.Type {
+	case html.TextNode:
+		if c.inPre {
+			return n.Data
+		}
+		text := spaceRegexp.ReplaceAllString(n.Data, " ")
+		if c.inLink {
+			return text
+		}
+		return linkTimestamps(text, c.episode)
+	case html.ElementNode:
+	default:
+		return renderChildren(n, c)
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Audio, atom.Video, atom.Iframe, atom.Head:
+		return ""
+	case atom.Br:
+		return "  \n"
+	case atom.Hr:
+		return "\n\n---\n\n"
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		return fmt.Sprintf("\n\n%s %s\n\n", strings.Repeat("#", level), strings.TrimSpace(renderChildren(n, c)))
+	case atom.Strong, atom.B:
+		return wrapInline(renderChildren(n, c), "**")
+	case atom.Em, atom.I:
+		return wrapInline(renderChildren(n, c), "_")
+	case atom.Code:
+		if c.inPre {
+			return renderChildren(n, c)
+		}
+		return wrapInline(renderChildren(n, c), "`")
+	case atom.Pre:
+		c.inPre = true
+		text := renderChildren(n, c)
+		c.inPre = false
+		return "\n\n```\n" + strings.Trim(text, "\n") + "\n```\n\n"
+	case atom.A:
+		href := strings.TrimSpace(attr(n, "href"))
+		inLink := c.inLink
+		c.inLink = true
+		text := strings.TrimSpace(renderChildren(n, c))
+		c.inLink = inLink
+		switch {
+		case href == "" || strings.HasPrefix(href, "javascript:"):
+			return text
+		case text == "":
+			return "<" + href + ">"
+		default:
+			return fmt.Sprintf("[%s](%s)", text, escapeURL(href))
+		}
+	case atom.Img:
+		src := attr(n, "src")
+		if src == "" {
+			return ""
+		}
+		return fmt.Sprintf("![%s](%s)", attr(n, "alt"), escapeURL(src))
+	case atom.Ul, atom.Ol:
+		c.lists = append(c.lists, &mdList{ordered: n.DataAtom == atom.Ol})
+		inner := renderChildren(n, c)
+		c.lists = c.lists[:len(c.lists)-1]
+		if len(c.lists) > 0 {
+			return "\n" + inner
+		}
+		return "\n\n" + inner + "\n\n"
+	case atom.Li:
+		// nested lists are indented by the enclosing item
+		marker := "- "
+		if len(c.lists) > 0 {
+			if l := c.lists[len(c.lists)-1]; l.ordered {
+				l.index++
+				marker = fmt.Sprintf("%d. ", l.index)
+			}
+		}
+		inner := strings.TrimSpace(cleanMarkdown(renderChildren(n, c)))
+		return marker + indentLines(inner, len(marker)) + "\n"
+	case atom.Blockquote:
+		inner := strings.TrimSpace(cleanMarkdown(renderChildren(n, c)))
+		return "\n\n> " + strings.ReplaceAll(inner, "\n", "\n> ") + "\n\n"
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Table, atom.Tr:
+		return "\n\n" + renderChildren(n, c) + "\n\n"
+	default:
+		return renderChildren(n, c)
+	}
+}
+
+// indentLines indents the lines after the first by `width`, except the
+// blank ones
+func indentLines(s string, width int) string {
+	lines := strings.Split(s, "\n")
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != "" {
+			lines[i] = strings.Repeat(indentMark, width) + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// wrapInline keeps the surrounding spaces outside the markers, which would
+// otherwise not be parsed as emphasis
+func wrapInline(s, marker string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+	leading := s[:strings.Index(s, trimmed)]
+	trailing := s[len(leading)+len(trimmed):]
+	return leading + marker + trimmed + marker + trailing
+}
+
+func escapeURL(u string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(u)
+}
+
+func linkTimestamps(text, episode string) string {
+	return timestampRegexp.ReplaceAllStringFunc(text, func(ts string) string {
+		seconds, err := parseTimestamp(ts)
+		if err != nil {
+			return ts
+		}
+		return fmt.Sprintf("[%s](%s%s)", ts, seekURL, url.QueryEscape(seekArg(episode, seconds)))
+	})
+}
+
+var blankLinesRegexp = regexp.MustCompile(`\n[ \t]*(\n[ \t]*)+\n`)
+
+func cleanMarkdown(s string) string {
+	lines := strings.Split(s, "\n")
+	inFence := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimLeft(line, " \t"+indentMark), "```") {
+			inFence = !inFence
+		} else if inFence {
+			continue
+		}
+		// keep the two trailing spaces of hard line breaks
+		if strings.HasSuffix(line, "  ") && strings.TrimSpace(line) != "" {
+			lines[i] = strings.TrimRight(line, " ") + "  "
+		} else {
+			lines[i] = strings.TrimRight(line, " \t")
+		}
+		// the spaces left of the text come from the HTML; list items are
+		// indented with `indentMark`
+		lines[i] = strings.TrimLeft(lines[i], " ")
+	}
+	s = strings.Join(lines, "\n")
+	s = blankLinesRegexp.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
+
+type Link struct {
+	Text   string `json:"text"`
+	URL    string `json:"url"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+var (
+	isbnRegexp   = regexp.MustCompile(`(?:^|[^\d])(97[89]\d{10}|\d{9}[\dXx])(?:[^\d]|$)`)
+	asinRegexp   = regexp.MustCompile(`/(?:dp|gp/product)/([A-Z0-9]{10})`)
+	handleRegexp = regexp.MustCompile(`^/@?([A-Za-z0-9_]{1,30})/?$`)
+	mastodonPath = regexp.MustCompile(`^/@([A-Za-z0-9_.]+)/?$`)
+)
+
+// sites with `/@name` profiles that are not Mastodon instances
+var atHandleHosts = []string{
+	"medium.com", "youtube.com", "substack.com", "tiktok.com", "threads.net", "threads.com",
+	"vimeo.com", "flickr.com", "behance.net", "dribbble.com", "giphy.com", "pinterest.com",
+}
+
+func hostIn(host string, hosts []string) bool {
+	for _, h := range hosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+var podcastHosts = []string{
+	"pocketcasts.com", "pca.st", "podcasts.apple.com", "overcast.fm", "castro.fm",
+	"podcastaddict.com", "castbox.fm", "xiaoyuzhoufm.com",
+}
+
+// ExtractLinks returns the distinct links of the show notes in their order,
+// the timestamps seeking in `episode` (`podcastUuid/uuid`)
+func ExtractLinks(showNotes, episode string) ([]*Link, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(showNotes), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return nil, err
+	}
+	links := make([]*Link, 0)
+	seen := make(map[string]*Link)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			href := strings.TrimSpace(attr(n, "href"))
+			text := stripHTML(renderChildren(n, &mdContext{inLink: true}))
+			if link := classifyLink(href, text, episode); link != nil {
+				key := normalizeLinkURL(link.URL)
+				if link.Kind == "timestamp" {
+					key = "t=" + link.Detail
+				}
+				if l, ok := seen[key]; ok {
+					// prefer a descriptive anchor text over the bare URL
+					if l.Text == l.URL && text != "" {
+						l.Text = text
+					}
+				} else {
+					seen[key] = link
+					links = append(links, link)
+				}
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+	// timestamps mentioned in the text are links too
+	for _, m := range timestampRegexp.FindAllString(stripHTML(showNotes), -1) {
+		if seconds, err := parseTimestamp(m); err == nil {
+			key := fmt.Sprintf("t=%d", seconds)
+			if _, ok := seen[key]; !ok {
+				link := &Link{Text: m, URL: seekURL + url.QueryEscape(seekArg(episode, seconds)), Kind: "timestamp", Detail: fmt.Sprintf("%d", seconds)}
+				seen[key] = link
+				links = append(links, link)
+			}
+		}
+	}
+	return links, nil
+}
+
+func normalizeLinkURL(u string) string {
+	if parsed, err := url.Parse(u); err == nil && parsed.Host != "" {
+		host := strings.TrimPrefix(strings.ToLower(parsed.Host), "www.")
+		return host + strings.TrimSuffix(parsed.EscapedPath(), "/") + "?" + parsed.RawQuery
+	}
+	return u
+}
+
+func classifyLink(href, text, episode string) *Link {
+	if text == "" {
+		text = href
+	}
+	if href == "" || strings.HasPrefix(href, "javascript:") || strings.HasPrefix(href, "mailto:") {
+		return nil
+	}
+	// anchors like `#t=12:30` or `#12:30` point into the episode
+	if strings.HasPrefix(href, "#") {
+		ts := strings.TrimPrefix(strings.TrimPrefix(href, "#"), "t=")
+		if seconds, err := parseTimestamp(ts); err == nil {
+			return &Link{Text: text, URL: seekURL + url.QueryEscape(seekArg(episode, seconds)), Kind: "timestamp", Detail: fmt.Sprintf("%d", seconds)}
+		}
+		return nil
+	}
+	u, err := url.Parse(href)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil
+	}
+	link := &Link{Text: text, URL: u.String(), Kind: "web"}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	switch {
+	case host == "twitter.com" || host == "x.com":
+		if m := handleRegexp.FindStringSubmatch(u.Path); m != nil {
+			link.Kind, link.Detail = "social", "@"+m[1]
+		}
+	case mastodonPath.MatchString(u.Path) && !hostIn(host, atHandleHosts):
+		link.Kind, link.Detail = "social", "@"+mastodonPath.FindStringSubmatch(u.Path)[1]+"@"+host
+	case strings.Contains(host, "amazon.") || strings.HasPrefix(host, "amzn."):
+		if m := asinRegexp.FindStringSubmatch(u.Path); m != nil {
+			link.Kind, link.Detail = "book", "ASIN "+m[1]
+		}
+	case host == "goodreads.com" || host == "bookshop.org" || strings.HasPrefix(host, "books.google."):
+		link.Kind = "book"
+	}
+	if link.Kind == "web" && hostIn(host, podcastHosts) {
+		link.Kind = "podcast"
+	}
+	if link.Kind == "web" || (link.Kind == "book" && link.Detail == "") {
+		if m := isbnRegexp.FindStringSubmatch(u.Path + "?" + u.RawQuery); m != nil && validISBN(m[1]) {
+			link.Kind, link.Detail = "book", "ISBN "+m[1]
+		}
+	}
+	return link
+}
+
+func validISBN(isbn string) bool {
+	sum := 0
+	switch len(isbn) {
+	case 10:
+		for i, r := range strings.ToUpper(isbn) {
+			d := int(r - '0')
+			if r == 'X' {
+				d = 10
+			}
+			sum += d * (10 - i)
+		}
+		return sum%11 == 0
+	case 13:
+		for i, r := range isbn {
+			d := int(r - '0')
+			if i%2 == 1 {
+				d *= 3
+			}
+			sum += d
+		}
+		return sum%10 == 0
+	}
+	return false
+}
+
+func LinksMarkdown(links []*Link) string {
+	list := make([]string, 0, len(links))
+	for _, l := range links {
+		if l.Kind != "timestamp" {
+			list = append(list, fmt.Sprintf("- [%s](%s)", l.Text, escapeURL(l.URL)))
+		}
+	}
+	return strings.Join(list, "\n")
+}
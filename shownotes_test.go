@@ -0,0 +1,101 @@
+package main_test
+
+import (
+	"testing"
+
+	"github.com/twio142/alfred-podcasts"
+)
+
+func TestExtractLinks(t *testing.T) {
+	tests := []struct {
+		name      string // description of this test case
+		showNotes string
+		wantKinds []string
+	}{
+		{
+			name:      "classify links",
+			showNotes: `<p><a href="https://twitter.com/jack">Jack</a> <a href="https://www.amazon.com/dp/0262033844">Book</a> <a href="https://overcast.fm/+abc">Show</a> <a href="https://example.com">Site</a></p>`,
+			wantKinds: []string{"social", "book", "podcast", "web"},
+		},
+		{
+			name:      "dedupe links",
+			showNotes: `<a href="https://example.com/a">A</a> <a href="https://www.example.com/a/">https://www.example.com/a/</a>`,
+			wantKinds: []string{"web"},
+		},
+		{
+			name:      "mastodon handles",
+			showNotes: `<a href="https://mastodon.social/@alice">Alice</a> <a href="https://medium.com/@bob">Bob</a> <a href="https://www.youtube.com/@carol">Carol</a> <a href="https://sub.substack.com/@dave">Dave</a>`,
+			wantKinds: []string{"social", "web", "web", "web"},
+		},
+		{
+			name:      "timestamps",
+			showNotes: `<p>00:00 Intro<br>12:30 <a href="#t=12:30">Topic</a><br>1:02:03 Outro</p>`,
+			wantKinds: []string{"timestamp", "timestamp", "timestamp"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := main.ExtractLinks(tt.showNotes, "p/e")
+			if gotErr != nil {
+				t.Fatalf("ExtractLinks() failed: %v", gotErr)
+			}
+			if len(got) != len(tt.wantKinds) {
+				t.Fatalf("ExtractLinks() = %d links, want %d", len(got), len(tt.wantKinds))
+			}
+			for i, l := range got {
+				if l.Kind != tt.wantKinds[i] {
+					t.Errorf("ExtractLinks()[%d].Kind = %s, want %s", i, l.Kind, tt.wantKinds[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRenderShownotes(t *testing.T) {
+	tests := []struct {
+		name      string // description of this test case
+		showNotes string
+		want      string
+	}{
+		{
+			name:      "heading and paragraphs",
+			showNotes: "<h2> Topics </h2><p>\n  First line<br>\n  second line</p>",
+			want:      "## Topics\n\nFirst line  \nsecond line",
+		},
+		{
+			name: "nested list with continuation and code",
+			showNotes: `<ul>
+  <li>First <a href="https://example.com/a">link</a>
+    <p>Continued paragraph</p>
+    <pre><code>  indented code
+line two</code></pre>
+    <ul><li>Nested <b>bold</b></li><li>Second nested</li></ul>
+  </li>
+  <li>Last</li>
+</ul>
+<ol><li>One</li><li>Two</li></ol>`,
+			want: "- First [link](https://example.com/a)\n\n  Continued paragraph\n\n  ```\n    indented code\n  line two\n  ```\n\n  - Nested **bold**\n  - Second nested\n- Last\n\n1. One\n2. Two",
+		},
+		{
+			name:      "image",
+			showNotes: `<p><img src="https://example.com/a b.png" alt="Cover"> Text</p>`,
+			want:      "![Cover](https://example.com/a%20b.png) Text",
+		},
+		{
+			name:      "timestamp",
+			showNotes: `<p>12:30 Topic, <a href="https://example.com/1:00">not 1:00</a></p>`,
+			want:      "[12:30](alfred://runtrigger/com.twio142.podcasts/seek/?argument=p%2Fe%40750) Topic, [not 1:00](https://example.com/1:00)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := main.RenderShownotes(tt.showNotes, "p/e")
+			if gotErr != nil {
+				t.Fatalf("RenderShownotes() failed: %v", gotErr)
+			}
+			if got != tt.want {
+				t.Errorf("RenderShownotes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
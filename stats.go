@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// flexInt accepts numbers sent either as JSON numbers or as strings
+type flexInt int
+
+func (n *flexInt) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*n = 0
+		return nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*n = flexInt(f)
+	return nil
+}
+
+type PodcastTime struct {
+	Name    string
+	UUID    string
+	Seconds int
+}
+
+type WeekCount struct {
+	Week  time.Time
+	Count int
+}
+
+type Stats struct {
+	Listened        int // seconds
+	Skipping        int
+	IntroSkipping   int
+	VariableSpeed   int
+	SilenceRemoval  int
+	StartedAt       time.Time
+	PodcastTime     []PodcastTime
+	FinishedPerWeek []WeekCount
+	QueueLength     int
+	QueueAge        time.Duration // average
+}
+
+const statsWeeks = 8
+
+func (s *Stats) Saved() int {
+	return s.Skipping + s.IntroSkipping + s.VariableSpeed + s.SilenceRemoval
+}
+
+func getServerStats(force bool) (*PocketCastsStatsResponse, error) {
+	maxAge := time.Duration(config.Cache.Lists)
+	if force {
+		maxAge = 0
+	}
+	file := getCachePath("stats")
+	var response PocketCastsStatsResponse
+	if data, err := readCache(file, maxAge, "stats"); err == nil {
+		if err := json.Unmarshal(data, &response); err == nil {
+			return &response, nil
+		}
+	}
+	body := map[string]any{}
+	if err := PocketCastsRequest("/user/stats/summary", &body, &response); err != nil {
+		return nil, err
+	}
+	data, _ := json.Marshal(response)
+	_ = writeCache(file, data)
+	return &response, nil
+}
+
+func GetStats(force bool) (*Stats, error) {
+	server, err := getServerStats(force)
+	if err != nil {
+		return nil, err
+	}
+	s := &Stats{
+		Listened:       int(server.Listened),
+		Skipping:       int(server.Skipping),
+		IntroSkipping:  int(server.IntroSkipping),
+		VariableSpeed:  int(server.VariableSpeed),
+		SilenceRemoval: int(server.SilenceRemoval),
+		StartedAt:      server.StartedAt,
+	}
+
+	if err := GetAllPodcasts(false); err == nil {
+		for _, p := range podcastMap {
+			pt := PodcastTime{Name: p.Name, UUID: p.UUID}
+			for _, e := range p.EpisodeMap {
+				if e.IsPlayed() {
+					pt.Seconds += e.Duration
+				} else {
+					pt.Seconds += e.PlayedUpTo
+				}
+			}
+			if pt.Seconds > 0 {
+				s.PodcastTime = append(s.PodcastTime, pt)
+			}
+		}
+		sort.Slice(s.PodcastTime, func(i, j int) bool {
+			return s.PodcastTime[i].Seconds > s.PodcastTime[j].Seconds
+		})
+	}
+
+	s.FinishedPerWeek = finishedPerWeek(readSessions(), time.Now(), statsWeeks)
+
+	if queue, err := GetUpNext(false); err == nil && len(queue) > 0 {
+		var total time.Duration
+		for _, e := range queue {
+			total += time.Since(e.Date)
+		}
+		s.QueueLength = len(queue)
+		s.QueueAge = total / time.Duration(len(queue))
+	}
+	return s, nil
+}
+
+// Session is an episode finished through the workflow, kept in the local
+// `sessions` log since Pocket Casts does not tell when an episode was played
+type Session struct {
+	Time        time.Time `json:"time"`
+	UUID        string    `json:"uuid"`
+	PodcastUUID string    `json:"podcast"`
+	Duration    int       `json:"duration"`
+}
+
+func recordFinished(episodes ...*Episode) {
+	f, err := os.OpenFile(getCachePath("sessions"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+	for _, e := range episodes {
+		data, _ := json.Marshal(Session{Time: time.Now(), UUID: e.UUID, PodcastUUID: e.PodcastUUID, Duration: e.Duration})
+		_, _ = f.Write(append(data, '\n'))
+	}
+}
+
+func readSessions() []*Session {
+	sessions := make([]*Session, 0)
+	f, err := os.Open(getCachePath("sessions"))
+	if err != nil {
+		return sessions
+	}
+	defer func() { _ = f.Close() }()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s Session
+		if err := json.Unmarshal(scanner.Bytes(), &s); err == nil {
+			sessions = append(sessions, &s)
+		}
+	}
+	return sessions
+}
+
+// finishedPerWeek counts distinct episodes finished in each of the last
+// `weeks` weeks, starting on Monday, oldest first
+func finishedPerWeek(sessions []*Session, now time.Time, weeks int) []WeekCount {
+	y, m, d := now.Date()
+	monday := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	monday = monday.AddDate(0, 0, -(int(monday.Weekday())+6)%7)
+	counts := make([]WeekCount, weeks)
+	for i := range counts {
+		counts[i].Week = monday.AddDate(0, 0, -7*(weeks-1-i))
+	}
+	seen := make(map[string]bool)
+	for _, s := range sessions {
+		if seen[s.UUID] || s.Time.Before(counts[0].Week) {
+			continue
+		}
+		seen[s.UUID] = true
+		i := int(s.Time.Sub(counts[0].Week) / (7 * 24 * time.Hour))
+		if i >= 0 && i < weeks {
+			counts[i].Count++
+		}
+	}
+	return counts
+}
+
+// asciiBar is padded to `width` so that columns line up
+func asciiBar(value, maxValue, width int) string {
+	filled := 0
+	if maxValue > 0 {
+		filled = min(max(value*width/maxValue, 0), width)
+	}
+	if filled == 0 && value > 0 {
+		return "▏" + strings.Repeat(" ", width-1)
+	}
+	return strings.Repeat("█", filled) + strings.Repeat(" ", width-filled)
+}
+
+func (s *Stats) Markdown() string {
+	var b strings.Builder
+	b.WriteString("# Listening Statistics\n\n")
+	if !s.StartedAt.IsZero() {
+		fmt.Fprintf(&b, "_Since %s_\n\n", s.StartedAt.Format("January 2, 2006"))
+	}
+	fmt.Fprintf(&b, "- Time listened: **%s**\n", formatHours(s.Listened))
+	fmt.Fprintf(&b, "- Time saved: **%s**\n", formatHours(s.Saved()))
+	fmt.Fprintf(&b, "    - Variable speed: %s\n", formatHours(s.VariableSpeed))
+	fmt.Fprintf(&b, "    - Trimmed silence: %s\n", formatHours(s.SilenceRemoval))
+	fmt.Fprintf(&b, "    - Skipping: %s\n", formatHours(s.Skipping))
+	fmt.Fprintf(&b, "    - Skipped intros: %s\n", formatHours(s.IntroSkipping))
+	if s.QueueLength > 0 {
+		fmt.Fprintf(&b, "- Up Next: %d episodes, %s old on average\n", s.QueueLength, formatHours(int(s.QueueAge.Seconds())))
+	}
+
+	if len(s.PodcastTime) > 0 {
+		b.WriteString("\n## Time per Podcast\n\n```\n")
+		top := s.PodcastTime[:min(15, len(s.PodcastTime))]
+		width := 0
+		for _, pt := range top {
+			width = max(width, len([]rune(pt.Name)))
+		}
+		for _, pt := range top {
+			name := pt.Name + strings.Repeat(" ", width-len([]rune(pt.Name)))
+			fmt.Fprintf(&b, "%s  %s %s\n", name, asciiBar(pt.Seconds, top[0].Seconds, 30), formatHours(pt.Seconds))
+		}
+		b.WriteString("```\n")
+	}
+
+	b.WriteString("\n## Episodes Finished per Week\n\n```\n")
+	most := 0
+	for _, w := range s.FinishedPerWeek {
+		most = max(most, w.Count)
+	}
+	for _, w := range s.FinishedPerWeek {
+		fmt.Fprintf(&b, "%s  %s %d\n", w.Week.Format("Jan 02"), asciiBar(w.Count, most, 20), w.Count)
+	}
+	b.WriteString("```\n")
+	return b.String()
+}
+
+func svgBars(labels []string, values []int, format func(int) string) string {
+	const barHeight, gap, labelWidth, barWidth = 18, 6, 220, 300
+	most := 0
+	for _, v := range values {
+		most = max(most, v)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="-apple-system, sans-serif" font-size="12">`,
+		labelWidth+barWidth+80, len(values)*(barHeight+gap))
+	for i, v := range values {
+		y := i * (barHeight + gap)
+		w := 0
+		if most > 0 {
+			w = v * barWidth / most
+		}
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`, labelWidth-8, y+13, html.EscapeString(labels[i]))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" rx="3" fill="#f43e37"/>`, labelWidth, y, w, barHeight)
+		fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`, labelWidth+w+6, y+13, html.EscapeString(format(v)))
+	}
+	b.WriteString("</svg>")
+	return b.String()
+}
+
+func (s *Stats) HTML() string {
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html><html><head><meta charset="utf-8"><title>Listening Statistics</title>`)
+	b.WriteString(`<style>body{font-family:-apple-system,sans-serif;max-width:720px;margin:2em auto;padding:0 1em}</style></head><body>`)
+	b.WriteString("<h1>Listening Statistics</h1>")
+	if !s.StartedAt.IsZero() {
+		fmt.Fprintf(&b, "<p><em>Since %s</em></p>", s.StartedAt.Format("January 2, 2006"))
+	}
+	fmt.Fprintf(&b, "<ul><li>Time listened: <strong>%s</strong></li>", formatHours(s.Listened))
+	fmt.Fprintf(&b, "<li>Time saved: <strong>%s</strong><ul><li>Variable speed: %s</li><li>Trimmed silence: %s</li><li>Skipping: %s</li><li>Skipped intros: %s</li></ul></li>",
+		formatHours(s.Saved()), formatHours(s.VariableSpeed), formatHours(s.SilenceRemoval), formatHours(s.Skipping), formatHours(s.IntroSkipping))
+	if s.QueueLength > 0 {
+		fmt.Fprintf(&b, "<li>Up Next: %d episodes, %s old on average</li>", s.QueueLength, formatHours(int(s.QueueAge.Seconds())))
+	}
+	b.WriteString("</ul>")
+
+	if len(s.PodcastTime) > 0 {
+		b.WriteString("<h2>Time per Podcast</h2>")
+		top := s.PodcastTime[:min(15, len(s.PodcastTime))]
+		labels, values := make([]string, len(top)), make([]int, len(top))
+		for i, pt := range top {
+			labels[i], values[i] = pt.Name, pt.Seconds
+		}
+		b.WriteString(svgBars(labels, values, formatHours))
+	}
+
+	b.WriteString("<h2>Episodes Finished per Week</h2>")
+	labels, values := make([]string, len(s.FinishedPerWeek)), make([]int, len(s.FinishedPerWeek))
+	for i, w := range s.FinishedPerWeek {
+		labels[i], values[i] = w.Week.Format("Jan 02"), w.Count
+	}
+	b.WriteString(svgBars(labels, values, strconv.Itoa))
+	b.WriteString("</body></html>\n")
+	return b.String()
+}
+
+// ExportStats writes the report as `md` or `html` and returns its path
+func ExportStats(format string) (string, error) {
+	s, err := GetStats(false)
+	if err != nil {
+		return "", err
+	}
+	var content string
+	switch format {
+	case "md":
+		content = s.Markdown()
+	case "html":
+		content = s.HTML()
+	default:
+		return "", fmt.Errorf("invalid report format: %s", format)
+	}
+	file := getCachePath("listening_stats." + format)
+	if err := writeCache(file, []byte(content)); err != nil {
+		return "", err
+	}
+	return file, nil
+}
@@ -0,0 +1,45 @@
+package main_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/twio142/alfred-podcasts"
+)
+
+func TestStats_Markdown(t *testing.T) {
+	s := &main.Stats{
+		Listened:      90000,
+		VariableSpeed: 3600,
+		Skipping:      1500,
+		StartedAt:     time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC),
+		PodcastTime: []main.PodcastTime{
+			{Name: "Long Show", Seconds: 7200},
+			{Name: "Short", Seconds: 1800},
+		},
+		FinishedPerWeek: []main.WeekCount{
+			{Week: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Count: 4},
+			{Week: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), Count: 0},
+		},
+	}
+	tests := []struct {
+		name string // description of this test case
+		want string
+	}{
+		{name: "total", want: "Time listened: **1d 1h**"},
+		{name: "saved", want: "Time saved: **1h 25m**"},
+		{name: "since", want: "_Since March 1, 2020_"},
+		{name: "podcast bar", want: "Long Show  ██████████████████████████████ 2h 00m"},
+		{name: "aligned names", want: "Short      ███████                        30m"},
+		{name: "weeks", want: "Jan 08" + strings.Repeat(" ", 23) + "0"},
+	}
+	md := s.Markdown()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(md, tt.want) {
+				t.Errorf("Markdown() does not contain %q:\n%s", tt.want, md)
+			}
+		})
+	}
+}
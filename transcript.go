@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type TranscriptInfo struct {
+	URL      string `json:"url"`
+	Type     string `json:"type"`
+	Language string `json:"language,omitempty"`
+}
+
+type Segment struct {
+	Start   float64 `json:"start"`
+	End     float64 `json:"end,omitempty"`
+	Speaker string  `json:"speaker,omitempty"`
+	Text    string  `json:"text"`
+}
+
+// formats with timing first
+var transcriptPreference = []string{"json", "vtt", "srt", "html"}
+
+func transcriptFormat(mimeType string, data []byte) string {
+	switch t := strings.ToLower(mimeType); {
+	case strings.Contains(t, "json"):
+		return "json"
+	case strings.Contains(t, "vtt"):
+		return "vtt"
+	case strings.Contains(t, "srt"), strings.Contains(t, "subrip"):
+		return "srt"
+	case strings.Contains(t, "html"):
+		return "html"
+	}
+	if data == nil {
+		return ""
+	}
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	switch {
+	case bytes.HasPrefix(data, []byte("WEBVTT")):
+		return "vtt"
+	case bytes.HasPrefix(data, []byte("{")):
+		return "json"
+	case bytes.HasPrefix(data, []byte("<")):
+		return "html"
+	default:
+		return "srt"
+	}
+}
+
+func (e *Episode) transcriptFile(ext string) string {
+	return getCachePath("transcripts", fmt.Sprintf("%s.%s.%s", e.PodcastUUID, e.UUID, ext))
+}
+
+func (e *Episode) GetTranscript(force bool) ([]*Segment, error) {
+	if e.UUID == "" || e.PodcastUUID == "" {
+		return nil, fmt.Errorf("episode info missing")
+	}
+	file := e.transcriptFile("json")
+	maxAge := time.Duration(math.MaxInt64)
+	if force {
+		maxAge = 0
+	}
+	segments := make([]*Segment, 0)
+	if data, err := readCache(file, maxAge); err == nil {
+		if err := json.Unmarshal(data, &segments); err == nil {
+			return segments, nil
+		}
+	}
+	if len(e.Transcripts) == 0 {
+		p := &Podcast{UUID: e.PodcastUUID}
+		if err := p.GetEpisodes(false); err == nil {
+			if _e, ok := p.EpisodeMap[e.UUID]; ok {
+				e.Transcripts = _e.Transcripts
+			}
+		}
+	}
+	t := e.preferredTranscript()
+	if t == nil {
+		return nil, fmt.Errorf("no transcript available")
+	}
+	data, err := fetchTranscript(t.URL)
+	if err != nil {
+		return nil, err
+	}
+	if segments, err = parseTranscript(data, t.Type); err != nil {
+		return nil, err
+	}
+	data, _ = json.Marshal(segments)
+	_ = writeCache(file, data)
+	_ = writeCache(e.transcriptFile("md"), []byte(renderTranscript(e, segments)))
+	return segments, nil
+}
+
+func (e *Episode) preferredTranscript() *TranscriptInfo {
+	for _, format := range transcriptPreference {
+		for _, t := range e.Transcripts {
+			if transcriptFormat(t.Type, nil) == format {
+				return t
+			}
+		}
+	}
+	if len(e.Transcripts) > 0 {
+		return e.Transcripts[0]
+	}
+	return nil
+}
+
+// CacheTranscript returns the path of the rendered transcript for QuickLook
+func (e *Episode) CacheTranscript() string {
+	file := e.transcriptFile("md")
+	if _, err := readCache(file, time.Duration(math.MaxInt64)); err == nil {
+		return file
+	}
+	if _, err := e.GetTranscript(false); err != nil {
+		return ""
+	}
+	return file
+}
+
+func fetchTranscript(u string) ([]byte, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching transcript: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("transcript request failed with status: %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func parseTranscript(data []byte, mimeType string) ([]*Segment, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	switch transcriptFormat(mimeType, data) {
+	case "json":
+		return parseJSONTranscript(data)
+	case "vtt", "srt":
+		return parseCues(data)
+	case "html":
+		return parseHTMLTranscript(data)
+	default:
+		return nil, fmt.Errorf("unsupported transcript type: %s", mimeType)
+	}
+}
+
+// parseCueTime parses `hh:mm:ss,ms` (SRT) and `hh:mm:ss.ms` or `mm:ss.ms` (WebVTT)
+func parseCueTime(s string) (float64, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", ".")
+	whole, frac, _ := strings.Cut(s, ".")
+	seconds, err := parseTimestamp(whole)
+	if err != nil {
+		return 0, err
+	}
+	t := float64(seconds)
+	if frac != "" {
+		f, err := strconv.ParseFloat("0."+frac, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp: %s", s)
+		}
+		t += f
+	}
+	return t, nil
+}
+
+var voiceRegexp = regexp.MustCompile(`^<v(?:\.[^ >]*)? ([^>]+)>`)
+
+// parseCues handles both SRT and WebVTT, which differ mostly in the header
+// and the decimal separator
+func parseCues(data []byte) ([]*Segment, error) {
+	segments := make([]*Segment, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var current *Segment
+	var lines []string
+	flush := func() {
+		if current != nil && len(lines) > 0 {
+			text := strings.Join(lines, " ")
+			if m := voiceRegexp.FindStringSubmatch(text); m != nil {
+				current.Speaker = strings.TrimSpace(m[1])
+			}
+			current.Text = stripHTML(text)
+			if current.Text != "" {
+				segments = append(segments, current)
+			}
+		}
+		current, lines = nil, nil
+	}
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			flush()
+		case strings.Contains(line, "-->"):
+			flush()
+			from, to, _ := strings.Cut(line, "-->")
+			start, err := parseCueTime(from)
+			if err != nil {
+				continue
+			}
+			// WebVTT cue settings follow the end time
+			end, _ := parseCueTime(strings.Fields(to + " ")[0])
+			current = &Segment{Start: start, End: end}
+		case current != nil:
+			lines = append(lines, line)
+		}
+	}
+	flush()
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("no transcript cues found")
+	}
+	return segments, nil
+}
+
+func parseJSONTranscript(data []byte) ([]*Segment, error) {
+	var transcript struct {
+		Segments []struct {
+			Start   float64 `json:"startTime"`
+			End     float64 `json:"endTime"`
+			Speaker string  `json:"speaker"`
+			Body    string  `json:"body"`
+		} `json:"segments"`
+	}
+	if err := json.Unmarshal(data, &transcript); err != nil {
+		return nil, fmt.Errorf("error decoding transcript: %v", err)
+	}
+	// segments are often single words, so join them into sentences
+	segments := make([]*Segment, 0)
+	var current *Segment
+	for _, s := range transcript.Segments {
+		text := strings.TrimSpace(s.Body)
+		if text == "" {
+			continue
+		}
+		if current == nil || (s.Speaker != "" && s.Speaker != current.Speaker) || sentenceEnded(current.Text) {
+			current = &Segment{Start: s.Start, Speaker: s.Speaker}
+			if current.Speaker == "" && len(segments) > 0 {
+				current.Speaker = segments[len(segments)-1].Speaker
+			}
+			segments = append(segments, current)
+		}
+		if current.Text != "" {
+			current.Text += " "
+		}
+		current.Text += text
+		current.End = s.End
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("no transcript segments found")
+	}
+	return segments, nil
+}
+
+func sentenceEnded(text string) bool {
+	if len(text) > 300 {
+		return true
+	}
+	text = strings.TrimRight(text, `"'”’)`)
+	return strings.HasSuffix(text, ".") || strings.HasSuffix(text, "?") || strings.HasSuffix(text, "!") ||
+		strings.HasSuffix(text, "。") || strings.HasSuffix(text, "？") || strings.HasSuffix(text, "！")
+}
+
+var htmlTranscriptRegexp = regexp.MustCompile(`(?is)<cite>(.*?)</cite>|<time>(.*?)</time>|<p>(.*?)</p>`)
+
+// parseHTMLTranscript reads the `<cite>`, `<time>` and `<p>` sequence
+// recommended for podcast:transcript HTML files
+func parseHTMLTranscript(data []byte) ([]*Segment, error) {
+	segments := make([]*Segment, 0)
+	speaker := ""
+	start := 0.0
+	for _, m := range htmlTranscriptRegexp.FindAllStringSubmatch(string(data), -1) {
+		switch {
+		case m[1] != "":
+			speaker = strings.TrimSuffix(strings.TrimSpace(stripHTML(m[1])), ":")
+		case m[2] != "":
+			if t, err := parseCueTime(html.UnescapeString(m[2])); err == nil {
+				start = t
+			}
+		default:
+			if text := stripHTML(m[3]); text != "" {
+				segments = append(segments, &Segment{Start: start, Speaker: speaker, Text: text})
+			}
+		}
+	}
+	if len(segments) == 0 {
+		text := stripHTML(string(data))
+		if text == "" {
+			return nil, fmt.Errorf("empty transcript")
+		}
+		segments = append(segments, &Segment{Text: text})
+	}
+	return segments, nil
+}
+
+func renderTranscript(e *Episode, segments []*Segment) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n_%s_\n\n", e.Title, e.Podcast)
+	speaker := ""
+	for _, s := range segments {
+		if s.Speaker != "" && s.Speaker != speaker {
+			speaker = s.Speaker
+			fmt.Fprintf(&b, "**%s**\n\n", speaker)
+		}
+		fmt.Fprintf(&b, "`%s` %s\n\n", formatTimestamp(int(s.Start)), s.Text)
+	}
+	return b.String()
+}
+
+func SearchTranscript(e *Episode, phrase string) ([]*Segment, error) {
+	segments, err := e.GetTranscript(false)
+	if err != nil {
+		return nil, err
+	}
+	phrase = strings.ToLower(strings.TrimSpace(phrase))
+	if phrase == "" {
+		return segments, nil
+	}
+	matches := make([]*Segment, 0)
+	for _, s := range segments {
+		if strings.Contains(strings.ToLower(s.Text), phrase) {
+			matches = append(matches, s)
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,51 @@
+package main_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/twio142/alfred-podcasts"
+)
+
+func TestSearchTranscript(t *testing.T) {
+	tests := []struct {
+		name string // description of this test case
+		// Named input parameters for target function.
+		episode *main.Episode
+		phrase  string
+		wantErr bool
+	}{
+		{
+			name: "valid search transcript",
+			episode: &main.Episode{
+				UUID:        "8befa1d7-a5fe-4e3f-a337-04afffb5679d",
+				PodcastUUID: "c1c38690-d8f4-013e-7c78-02d8c28b0a65",
+			},
+			phrase:  "the",
+			wantErr: false,
+		},
+		{
+			name:    "invalid search transcript",
+			episode: &main.Episode{UUID: "8befa1d7-a5fe-4e3f-a337-04afffb5679d"},
+			phrase:  "the",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotErr := main.SearchTranscript(tt.episode, tt.phrase)
+			if gotErr != nil {
+				if !tt.wantErr {
+					t.Errorf("SearchTranscript() failed: %v", gotErr)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Fatal("SearchTranscript() succeeded unexpectedly")
+			}
+			for _, s := range got {
+				fmt.Printf("%.0f\t%s\n", s.Start, s.Text)
+			}
+		})
+	}
+}
@@ -6,9 +6,11 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
+	"unicode"
 
 	"github.com/mozillazg/go-pinyin"
 )
@@ -26,16 +28,45 @@ func parseEpisodePath(rawURL string) (podcastUUID, episodeUUID string, err error
 	return segments[2], segments[4], nil
 }
 
+// matchString adds the pinyin of Han characters to `text`, per syllable and
+// joined for each run of characters, so `bo`, `ke` and `boke` match 播客
 func matchString(text ...string) string {
 	py := text
 	for _, t := range text {
-		for _, v := range pinyin.Convert(t, nil) {
-			py = append(py, strings.Join(v, ""))
+		for _, run := range hanRuns(t) {
+			syllables := make([]string, 0, len(run))
+			for _, v := range pinyin.Convert(run, nil) {
+				syllables = append(syllables, strings.Join(v, ""))
+			}
+			py = append(py, syllables...)
+			if len(syllables) > 1 {
+				py = append(py, strings.Join(syllables, ""))
+			}
 		}
 	}
 	return strings.Join(py, " ")
 }
 
+// hanRuns returns the runs of consecutive Han characters in `text`
+func hanRuns(text string) []string {
+	runs := make([]string, 0)
+	start := -1
+	for i, r := range text {
+		if unicode.Is(unicode.Han, r) {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 {
+			runs = append(runs, text[start:i])
+			start = -1
+		}
+	}
+	if start >= 0 {
+		runs = append(runs, text[start:])
+	}
+	return runs
+}
+
 func formatDuration(duration int) string {
 	if duration <= 0 {
 		return "--:--"
@@ -50,13 +81,62 @@ func formatDuration(duration int) string {
 	}
 }
 
-func downloadImage(url string, path string) {
-	scpt := fmt.Sprintf("curl -m 10 -o '%s' '%s' && file --mime-type -b '%s' | grep -q '^image/' || rm -f '%s'", path, url, path, path)
-	cmd := exec.Command("/bin/sh", "-c", scpt)
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setsid: true,
+// formatHours formats long durations, e.g. `3d 4h` or `2h 05m`
+func formatHours(duration int) string {
+	days := duration / 86400
+	hours := (duration % 86400) / 3600
+	minutes := (duration % 3600) / 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %02dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
 	}
-	_ = cmd.Start()
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// formatTimestamp is `formatDuration` for positions, where zero is valid
+func formatTimestamp(position int) string {
+	if position <= 0 {
+		return "00:00"
+	}
+	return formatDuration(position)
+}
+
+// parseTimestamp accepts `hh:mm:ss`, `mm:ss` or plain seconds
+func parseTimestamp(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty timestamp")
+	}
+	seconds := 0
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp: %s", s)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (i > 0 && n >= 60) {
+			return 0, fmt.Errorf("invalid timestamp: %s", s)
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds, nil
+}
+
+func progressBar(position, duration int) string {
+	const width = 10
+	if duration <= 0 {
+		return ""
+	}
+	filled := min(max(position*width/duration, 0), width)
+	return strings.Repeat("▰", filled) + strings.Repeat("▱", width-filled)
 }
 
 func getCachePath(parts ...string) string {
@@ -85,7 +165,8 @@ func writeCache(path string, data []byte) error {
 }
 
 func clearOldCache() {
-	scpt := fmt.Sprintf("find '%s' -type f -mtime +60 -delete", cacheDir+"/shownotes")
+	days := int(time.Duration(config.Cache.Retention) / (24 * time.Hour))
+	scpt := fmt.Sprintf("find '%s' '%s' -type f -mtime +%d -delete", cacheDir+"/shownotes", cacheDir+"/transcripts", days)
 	cmd := exec.Command("/bin/sh", "-c", scpt)
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Setsid: true,
@@ -97,6 +178,12 @@ func getLockFile(refreshTarget []string) string {
 	if refreshTarget[0] == "podcast" && len(refreshTarget) > 1 {
 		lockfile := fmt.Sprintf("%s.lock", refreshTarget[1])
 		return getCachePath("podcasts", lockfile)
+	} else if refreshTarget[0] == "artwork" && len(refreshTarget) > 1 {
+		lockfile := fmt.Sprintf("%s.episodes.lock", refreshTarget[1])
+		return getCachePath("artworks", lockfile)
+	} else if refreshTarget[0] == "filter" && len(refreshTarget) > 1 {
+		lockfile := fmt.Sprintf("filter.%s.lock", refreshTarget[1])
+		return getCachePath(lockfile)
 	} else {
 		lockfile := refreshTarget[0] + ".lock"
 		return getCachePath(lockfile)
@@ -115,8 +202,12 @@ func refreshInBackground(refreshTarget []string) {
 	_ = f.Close()
 	cmd := exec.Command(os.Args[0])
 	cmd.Env = append(os.Environ(), "refresh="+refreshTarget[0])
-	if refreshTarget[0] == "podcast" && len(refreshTarget) > 1 {
+	if (refreshTarget[0] == "podcast" || refreshTarget[0] == "artwork") && len(refreshTarget) > 1 {
 		cmd.Env = append(cmd.Env, "podcastUuid="+refreshTarget[1])
+	} else if refreshTarget[0] == "episode_artworks" && len(refreshTarget) > 1 {
+		cmd.Env = append(cmd.Env, "selection="+refreshTarget[1])
+	} else if refreshTarget[0] == "filter" && len(refreshTarget) > 1 {
+		cmd.Env = append(cmd.Env, "filterUuid="+refreshTarget[1])
 	}
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Setsid: true,
@@ -137,19 +228,95 @@ func refreshCache(refreshTarget []string) error {
 			return fmt.Errorf("no podcast name provided")
 		}
 		p := &Podcast{UUID: refreshTarget[1]}
-		return p.GetEpisodes(true)
+		if err := p.GetEpisodes(true); err != nil {
+			return err
+		}
+		return updateSearchIndex(p)
+	case "artwork":
+		if len(refreshTarget) < 2 {
+			return fmt.Errorf("no podcast name provided")
+		}
+		p := &Podcast{UUID: refreshTarget[1]}
+		if err := p.GetEpisodes(false); err != nil {
+			return err
+		}
+		p.CacheEpisodeArtworks()
+		return nil
+	case "episode_artworks":
+		if len(refreshTarget) < 2 {
+			return fmt.Errorf("no episodes provided")
+		}
+		cacheEpisodeArtworks(resolveEpisodes(parseSelection(refreshTarget[1])))
+		return nil
+	case "artworks":
+		if err := GetPodcastList(false); err != nil {
+			return err
+		}
+		CacheArtworks()
+		return nil
 	case "allPodcasts":
 		clearOldCache()
-		return GetAllPodcasts(true)
+		if err := GetAllPodcasts(true); err != nil {
+			return err
+		}
+		episodes := make([]*Episode, 0)
+		for _, p := range podcastMap {
+			for _, e := range p.EpisodeMap {
+				if e.Podcast == "" {
+					e.Podcast = p.Name
+				}
+				episodes = append(episodes, e)
+			}
+		}
+		if err := notifyNewEpisodes(episodes); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		idx := LoadSearchIndex()
+		idx.Update(podcastMap)
+		return idx.Save()
+	case "stats":
+		_, err := getServerStats(true)
+		return err
+	case "filters":
+		_, err := GetFilters(true)
+		return err
+	case "filter":
+		if len(refreshTarget) < 2 {
+			return fmt.Errorf("no filter provided")
+		}
+		if _, err := GetFilters(true); err != nil {
+			return err
+		}
+		f, err := GetFilter(refreshTarget[1])
+		if err != nil {
+			return err
+		}
+		_, err = f.GetEpisodes(true)
+		return err
 	case "up_next":
 		_, err := GetUpNext(true)
 		return err
+	case "discover":
+		return refreshDiscover()
 	default:
-		_, err := GetList(target, true)
+		episodes, err := GetList(target, true)
+		if err == nil && target == "new_releases" {
+			return notifyNewEpisodes(episodes)
+		}
 		return err
 	}
 }
 
+func openURL(u string) error {
+	return exec.Command("/usr/bin/open", u).Run()
+}
+
+func copyToClipboard(text string) error {
+	cmd := exec.Command("pbcopy")
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
 func Notify(message string, t ...string) {
 	cmd := exec.Command("terminal-notifier")
 	cmd.Args = append(cmd.Args, "-message", message, "-sender", "com.runningwithcrayons.Alfred", "-contentImage", "icon.png", "-title")